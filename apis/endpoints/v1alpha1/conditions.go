@@ -0,0 +1,129 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// Condition types of an Endpoint.
+const (
+	// TypeSecretOwnership indicates whether the secret referenced by an
+	// Endpoint is owned by it and can therefore be written or deleted.
+	TypeSecretOwnership xpv1.ConditionType = "SecretOwnership"
+
+	// TypeSecretTemplate indicates whether the secret template of an
+	// Endpoint can be rendered.
+	TypeSecretTemplate xpv1.ConditionType = "SecretTemplate"
+
+	// TypeAccount indicates whether the account of an Endpoint can
+	// issue tokens according to the ArgoCD accounts ConfigMap.
+	TypeAccount xpv1.ConditionType = "Account"
+)
+
+// Reasons an Endpoint does or does not own its secret.
+const (
+	ReasonSecretOwned    xpv1.ConditionReason = "SecretOwned"
+	ReasonSecretNotOwned xpv1.ConditionReason = "SecretNotOwned"
+)
+
+// Reasons an Endpoint secret template is or is not valid.
+const (
+	ReasonTemplateValid xpv1.ConditionReason = "TemplateValid"
+	ReasonTemplateError xpv1.ConditionReason = "TemplateError"
+)
+
+// Reasons an Endpoint account is or is not usable.
+const (
+	ReasonAccountAvailable xpv1.ConditionReason = "AccountAvailable"
+	ReasonAccountRemoved   xpv1.ConditionReason = "AccountRemoved"
+	ReasonAccountDisabled  xpv1.ConditionReason = "AccountDisabled"
+	ReasonAccountNoAPIKey  xpv1.ConditionReason = "AccountNoAPIKey"
+)
+
+// SecretOwned returns a condition that indicates the referenced secret
+// is owned by the Endpoint.
+func SecretOwned() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeSecretOwnership,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonSecretOwned,
+	}
+}
+
+// SecretNotOwned returns a condition that indicates the referenced secret
+// already exists and was not created by the Endpoint, so it will be
+// neither overwritten nor deleted.
+func SecretNotOwned(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeSecretOwnership,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonSecretNotOwned,
+		Message:            msg,
+	}
+}
+
+// TemplateValid returns a condition that indicates the secret template
+// of the Endpoint can be rendered.
+func TemplateValid() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeSecretTemplate,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonTemplateValid,
+	}
+}
+
+// TemplateError returns a condition that indicates the secret template
+// of the Endpoint cannot be rendered.
+func TemplateError(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeSecretTemplate,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonTemplateError,
+		Message:            msg,
+	}
+}
+
+// AccountAvailable returns a condition that indicates the account of the
+// Endpoint can issue tokens.
+func AccountAvailable() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeAccount,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAccountAvailable,
+	}
+}
+
+// AccountRemoved returns a condition that indicates the account of the
+// Endpoint is no longer declared.
+func AccountRemoved(msg string) xpv1.Condition {
+	return accountUnavailable(ReasonAccountRemoved, msg)
+}
+
+// AccountDisabled returns a condition that indicates the account of the
+// Endpoint has been disabled.
+func AccountDisabled(msg string) xpv1.Condition {
+	return accountUnavailable(ReasonAccountDisabled, msg)
+}
+
+// AccountNoAPIKey returns a condition that indicates the account of the
+// Endpoint lacks the apiKey capability.
+func AccountNoAPIKey(msg string) xpv1.Condition {
+	return accountUnavailable(ReasonAccountNoAPIKey, msg)
+}
+
+func accountUnavailable(reason xpv1.ConditionReason, msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeAccount,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            msg,
+	}
+}
@@ -10,6 +10,16 @@ import (
 type EndpointObservation struct {
 	ID        string `json:"id,omitempty"`
 	ExpiresIn string `json:"expiresIn,omitempty"`
+
+	// ExpiresAt time the token expires at; unset if it never expires.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// ServerURL of the ArgoCD instance the token has been issued by.
+	ServerURL string `json:"serverUrl,omitempty"`
+
+	// ProviderConfigGeneration generation of the ProviderConfig last
+	// used to write the endpoint secret.
+	ProviderConfigGeneration int64 `json:"providerConfigGeneration,omitempty"`
 }
 
 // EndpointParameters are the configurable fields of an Endpoint.
@@ -21,17 +31,147 @@ type EndpointParameters struct {
 	// Account name
 	Account string `json:"account"`
 
-	// ExpiresIn duration before the token will expire. (Default: No expiration)
+	// ExpiresIn duration before the token will expire, e.g. '720h'.
+	// (Default: No expiration)
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	ExpiresIn string `json:"expiresIn,omitempty"`
+
+	// WriteSecretToRef the Kubernetes secret the token is written to.
+	// Optional only if vaultConfigRef is specified.
+	// +optional
+	WriteSecretToRef xpv1.SecretReference `json:"writeSecretToRef,omitempty"`
+
+	// VaultConfigRef references the VaultConfig used to write the endpoint
+	// secret to a Vault KV v2 secrets engine.
+	// +optional
+	VaultConfigRef *xpv1.Reference `json:"vaultConfigRef,omitempty"`
+
+	// AdditionalSecretRefs other secrets receiving a copy of the endpoint secret.
+	// +optional
+	AdditionalSecretRefs []xpv1.SecretReference `json:"additionalSecretRefs,omitempty"`
+
+	// NamespaceSelector selects the namespaces receiving a copy of the endpoint
+	// secret; copies are named as the writeSecretToRef secret.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// SecretTemplate customizes keys, labels and annotations of the endpoint secret.
+	// +optional
+	SecretTemplate *SecretTemplate `json:"secretTemplate,omitempty"`
+
+	// Encryption if specified the token is encrypted before being written,
+	// so that only the holder of the private key can read it.
 	// +optional
-	// ExpiresIn string `json:"expiresIn,omitempty"`
+	Encryption *TokenEncryption `json:"encryption,omitempty"`
 
-	WriteSecretToRef xpv1.SecretReference `json:"writeSecretToRef"`
+	// AccountRemovalPolicy what to do with the endpoint secret when the
+	// account is removed or disabled in the ArgoCD accounts ConfigMap.
+	// (Default: Retain)
+	// +optional
+	// +kubebuilder:validation:Enum=Retain;DeleteSecret
+	AccountRemovalPolicy AccountRemovalPolicy `json:"accountRemovalPolicy,omitempty"`
+}
+
+// AccountRemovalPolicy tells what to do with the endpoint secret when its
+// account is no longer usable.
+type AccountRemovalPolicy string
+
+// Account removal policies.
+const (
+	// AccountRemovalRetain keeps the endpoint secret.
+	AccountRemovalRetain AccountRemovalPolicy = "Retain"
+
+	// AccountRemovalDeleteSecret deletes the endpoint secret.
+	AccountRemovalDeleteSecret AccountRemovalPolicy = "DeleteSecret"
+)
+
+// TokenEncryption configures the encryption of the token at rest.
+type TokenEncryption struct {
+	// PublicKeyRef references the PEM encoded RSA public key the token
+	// is encrypted with (RSA-OAEP-256 + A256GCM).
+	PublicKeyRef PublicKeyReference `json:"publicKeyRef"`
+}
+
+// PublicKeyReference references a key of a Secret or a ConfigMap holding
+// a PEM encoded public key.
+type PublicKeyReference struct {
+	// Kind of the referenced object. (Default: Secret)
+	// +optional
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the referenced object.
+	Name string `json:"name"`
+
+	// Namespace of the referenced object.
+	Namespace string `json:"namespace"`
+
+	// Key holding the public key.
+	Key string `json:"key"`
+}
+
+// SecretFormat is the format of the secret written by an Endpoint.
+type SecretFormat string
+
+// Secret formats.
+const (
+	// SecretFormatToken writes the token and the server url only.
+	SecretFormatToken SecretFormat = "Token"
+
+	// SecretFormatArgoCDConfig also writes an argocd CLI config file
+	// under the 'config' key.
+	SecretFormatArgoCDConfig SecretFormat = "ArgoCDConfig"
+)
+
+// SecretTemplate customizes the secret written by an Endpoint.
+type SecretTemplate struct {
+	// Format of the secret. With 'ArgoCDConfig' a ready to use argocd CLI
+	// config file is written under the 'config' key. (Default: Token)
+	// +optional
+	// +kubebuilder:validation:Enum=Token;ArgoCDConfig
+	Format SecretFormat `json:"format,omitempty"`
+
+	// TokenKey name of the secret key holding the token. (Default: bearer)
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	TokenKey string `json:"tokenKey,omitempty"`
+
+	// TargetKey name of the secret key holding the ArgoCD server url. (Default: target)
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	TargetKey string `json:"targetKey,omitempty"`
+
+	// Labels added to the secret.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations added to the secret.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// SkipDefaultLabels if true the Krateo UI labels (icon, category, ...)
+	// are not added to the secret.
+	// +optional
+	SkipDefaultLabels bool `json:"skipDefaultLabels,omitempty"`
+
+	// Data maps additional secret keys to Go templates.
+	// Templates are rendered over the fields: .Token, .TokenID, .ServerURL,
+	// .Account, .ExpiresAt (RFC3339, empty if the token never expires) and .CA.
+	// +optional
+	Data map[string]string `json:"data,omitempty"`
 }
 
 // A EndpointSpec defines the desired state of an Endpoint.
 type EndpointSpec struct {
 	xpv1.ResourceSpec `json:",inline"`
-	ForProvider       EndpointParameters `json:"forProvider"`
+
+	// ProviderConfigSelector selects the ProviderConfig by labels; it takes
+	// precedence over providerConfigRef, which is set to the selected one.
+	// +optional
+	ProviderConfigSelector *metav1.LabelSelector `json:"providerConfigSelector,omitempty"`
+
+	ForProvider EndpointParameters `json:"forProvider"`
 }
 
 // A EndpointStatus represents the observed state of an Endpoint.
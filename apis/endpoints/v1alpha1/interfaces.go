@@ -0,0 +1,76 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// A TokenEndpoint issues an ArgoCD token and stores it in secrets: either
+// an Endpoint or a NamespacedEndpoint.
+// +kubebuilder:object:generate=false
+type TokenEndpoint interface {
+	metav1.Object
+	runtime.Object
+
+	// GetParameters returns the desired state in the Endpoint form.
+	GetParameters() EndpointParameters
+
+	// GetObservation returns the observed state of the token.
+	GetObservation() *EndpointObservation
+
+	// GetProviderConfigSelector returns the labels selecting the
+	// ProviderConfig, or the ProviderConfigBinding; nil if not set.
+	GetProviderConfigSelector() *metav1.LabelSelector
+}
+
+// GetParameters of this Endpoint.
+func (mg *Endpoint) GetParameters() EndpointParameters {
+	return mg.Spec.ForProvider
+}
+
+// GetObservation of this Endpoint.
+func (mg *Endpoint) GetObservation() *EndpointObservation {
+	return &mg.Status.AtProvider
+}
+
+// GetProviderConfigSelector of this Endpoint.
+func (mg *Endpoint) GetProviderConfigSelector() *metav1.LabelSelector {
+	return mg.Spec.ProviderConfigSelector
+}
+
+// GetParameters of this NamespacedEndpoint: the token is written to, and
+// the public key read from, the namespace of the NamespacedEndpoint.
+func (mg *NamespacedEndpoint) GetParameters() EndpointParameters {
+	p := mg.Spec.ForProvider
+
+	var enc *TokenEncryption
+	if p.Encryption != nil {
+		enc = p.Encryption.DeepCopy()
+		enc.PublicKeyRef.Namespace = mg.GetNamespace()
+	}
+
+	return EndpointParameters{
+		ID:        p.ID,
+		Account:   p.Account,
+		ExpiresIn: p.ExpiresIn,
+		WriteSecretToRef: xpv1.SecretReference{
+			Name:      p.WriteSecretToRef.Name,
+			Namespace: mg.GetNamespace(),
+		},
+		SecretTemplate:       p.SecretTemplate,
+		Encryption:           enc,
+		AccountRemovalPolicy: p.AccountRemovalPolicy,
+	}
+}
+
+// GetObservation of this NamespacedEndpoint.
+func (mg *NamespacedEndpoint) GetObservation() *EndpointObservation {
+	return &mg.Status.AtProvider
+}
+
+// GetProviderConfigSelector of this NamespacedEndpoint.
+func (mg *NamespacedEndpoint) GetProviderConfigSelector() *metav1.LabelSelector {
+	return mg.Spec.ProviderConfigSelector
+}
@@ -0,0 +1,85 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// NamespacedEndpointParameters are the configurable fields of a
+// NamespacedEndpoint; the token is always written to its own namespace.
+type NamespacedEndpointParameters struct {
+	// ID optional endpoint id. Fall back to uuid if not value specified
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// Account name
+	Account string `json:"account"`
+
+	// ExpiresIn duration before the token will expire, e.g. '720h'.
+	// (Default: No expiration)
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	ExpiresIn string `json:"expiresIn,omitempty"`
+
+	// WriteSecretToRef the secret, in the namespace of the
+	// NamespacedEndpoint, the token is written to.
+	WriteSecretToRef xpv1.LocalSecretReference `json:"writeSecretToRef"`
+
+	// SecretTemplate customizes the keys, labels, annotations and extra
+	// data of the endpoint secret.
+	// +optional
+	SecretTemplate *SecretTemplate `json:"secretTemplate,omitempty"`
+
+	// Encryption if set the token is encrypted with the referenced public
+	// key, in the namespace of the NamespacedEndpoint, before being stored.
+	// +optional
+	Encryption *TokenEncryption `json:"encryption,omitempty"`
+
+	// AccountRemovalPolicy what to do with the secret when the account is
+	// removed or can no longer have tokens (Default: Retain).
+	// +optional
+	// +kubebuilder:validation:Enum=Retain;DeleteSecret
+	AccountRemovalPolicy AccountRemovalPolicy `json:"accountRemovalPolicy,omitempty"`
+}
+
+// A NamespacedEndpointSpec defines the desired state of a NamespacedEndpoint.
+// Its providerConfigRef names a ProviderConfigBinding in the same namespace.
+type NamespacedEndpointSpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+
+	// ProviderConfigSelector selects the ProviderConfigBinding, in the same
+	// namespace, by labels; it takes precedence over providerConfigRef, which
+	// is set to the selected one.
+	// +optional
+	ProviderConfigSelector *metav1.LabelSelector `json:"providerConfigSelector,omitempty"`
+
+	ForProvider NamespacedEndpointParameters `json:"forProvider"`
+}
+
+// +kubebuilder:object:root=true
+
+// A NamespacedEndpoint is an Endpoint tenants can create in their own
+// namespace: the ArgoCD instance and the accounts it may use are controlled
+// by the ProviderConfigBinding it references.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:resource:scope=Namespaced,categories={crossplane,managed,krateo,argocd}
+// +kubebuilder:subresource:status
+type NamespacedEndpoint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NamespacedEndpointSpec `json:"spec"`
+	Status EndpointStatus         `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespacedEndpointList contains a list of NamespacedEndpoint
+type NamespacedEndpointList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespacedEndpoint `json:"items"`
+}
@@ -28,6 +28,15 @@ var (
 	EndpointGroupVersionKind = SchemeGroupVersion.WithKind(EndpointKind)
 )
 
+// NamespacedEndpoint type metadata.
+var (
+	NamespacedEndpointKind             = reflect.TypeOf(NamespacedEndpoint{}).Name()
+	NamespacedEndpointGroupKind        = schema.GroupKind{Group: Group, Kind: NamespacedEndpointKind}.String()
+	NamespacedEndpointKindAPIVersion   = NamespacedEndpointKind + "." + SchemeGroupVersion.String()
+	NamespacedEndpointGroupVersionKind = SchemeGroupVersion.WithKind(NamespacedEndpointKind)
+)
+
 func init() {
 	SchemeBuilder.Register(&Endpoint{}, &EndpointList{})
+	SchemeBuilder.Register(&NamespacedEndpoint{}, &NamespacedEndpointList{})
 }
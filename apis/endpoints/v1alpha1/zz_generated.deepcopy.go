@@ -22,7 +22,9 @@ limitations under the License.
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"github.com/crossplane/crossplane-runtime/apis/common/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -87,6 +89,10 @@ func (in *EndpointList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EndpointObservation) DeepCopyInto(out *EndpointObservation) {
 	*out = *in
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointObservation.
@@ -103,6 +109,31 @@ func (in *EndpointObservation) DeepCopy() *EndpointObservation {
 func (in *EndpointParameters) DeepCopyInto(out *EndpointParameters) {
 	*out = *in
 	out.WriteSecretToRef = in.WriteSecretToRef
+	if in.VaultConfigRef != nil {
+		in, out := &in.VaultConfigRef, &out.VaultConfigRef
+		*out = new(v1.Reference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AdditionalSecretRefs != nil {
+		in, out := &in.AdditionalSecretRefs, &out.AdditionalSecretRefs
+		*out = make([]v1.SecretReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretTemplate != nil {
+		in, out := &in.SecretTemplate, &out.SecretTemplate
+		*out = new(SecretTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(TokenEncryption)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointParameters.
@@ -119,7 +150,12 @@ func (in *EndpointParameters) DeepCopy() *EndpointParameters {
 func (in *EndpointSpec) DeepCopyInto(out *EndpointSpec) {
 	*out = *in
 	in.ResourceSpec.DeepCopyInto(&out.ResourceSpec)
-	out.ForProvider = in.ForProvider
+	if in.ProviderConfigSelector != nil {
+		in, out := &in.ProviderConfigSelector, &out.ProviderConfigSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.ForProvider.DeepCopyInto(&out.ForProvider)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointSpec.
@@ -136,7 +172,7 @@ func (in *EndpointSpec) DeepCopy() *EndpointSpec {
 func (in *EndpointStatus) DeepCopyInto(out *EndpointStatus) {
 	*out = *in
 	in.ResourceStatus.DeepCopyInto(&out.ResourceStatus)
-	out.AtProvider = in.AtProvider
+	in.AtProvider.DeepCopyInto(&out.AtProvider)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointStatus.
@@ -148,3 +184,177 @@ func (in *EndpointStatus) DeepCopy() *EndpointStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacedEndpoint) DeepCopyInto(out *NamespacedEndpoint) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedEndpoint.
+func (in *NamespacedEndpoint) DeepCopy() *NamespacedEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacedEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespacedEndpoint) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacedEndpointList) DeepCopyInto(out *NamespacedEndpointList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NamespacedEndpoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedEndpointList.
+func (in *NamespacedEndpointList) DeepCopy() *NamespacedEndpointList {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacedEndpointList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespacedEndpointList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacedEndpointParameters) DeepCopyInto(out *NamespacedEndpointParameters) {
+	*out = *in
+	out.WriteSecretToRef = in.WriteSecretToRef
+	if in.SecretTemplate != nil {
+		in, out := &in.SecretTemplate, &out.SecretTemplate
+		*out = new(SecretTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(TokenEncryption)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedEndpointParameters.
+func (in *NamespacedEndpointParameters) DeepCopy() *NamespacedEndpointParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacedEndpointParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacedEndpointSpec) DeepCopyInto(out *NamespacedEndpointSpec) {
+	*out = *in
+	in.ResourceSpec.DeepCopyInto(&out.ResourceSpec)
+	if in.ProviderConfigSelector != nil {
+		in, out := &in.ProviderConfigSelector, &out.ProviderConfigSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.ForProvider.DeepCopyInto(&out.ForProvider)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedEndpointSpec.
+func (in *NamespacedEndpointSpec) DeepCopy() *NamespacedEndpointSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacedEndpointSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PublicKeyReference) DeepCopyInto(out *PublicKeyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PublicKeyReference.
+func (in *PublicKeyReference) DeepCopy() *PublicKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(PublicKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretTemplate) DeepCopyInto(out *SecretTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Data != nil {
+		in, out := &in.Data, &out.Data
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretTemplate.
+func (in *SecretTemplate) DeepCopy() *SecretTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenEncryption) DeepCopyInto(out *TokenEncryption) {
+	*out = *in
+	out.PublicKeyRef = in.PublicKeyRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenEncryption.
+func (in *TokenEncryption) DeepCopy() *TokenEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -27,3 +27,12 @@ func (l *EndpointList) GetItems() []resource.Managed {
 	}
 	return items
 }
+
+// GetItems of this NamespacedEndpointList.
+func (l *NamespacedEndpointList) GetItems() []resource.Managed {
+	items := make([]resource.Managed, len(l.Items))
+	for i := range l.Items {
+		items[i] = &l.Items[i]
+	}
+	return items
+}
@@ -0,0 +1,47 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// Condition types of a ProviderConfig.
+const (
+	// TypeHealthy indicates whether the ArgoCD server of a ProviderConfig
+	// is reachable and accepts its credentials.
+	TypeHealthy xpv1.ConditionType = "Healthy"
+)
+
+// Reasons a ProviderConfig is or is not healthy.
+const (
+	ReasonHealthy       xpv1.ConditionReason = "Healthy"
+	ReasonUnreachable   xpv1.ConditionReason = "Unreachable"
+	ReasonVersionFailed xpv1.ConditionReason = "VersionFailed"
+	ReasonLoginFailed   xpv1.ConditionReason = "LoginFailed"
+	ReasonInvalidConfig xpv1.ConditionReason = "InvalidConfig"
+)
+
+// Healthy returns a condition that indicates the ArgoCD server is
+// reachable and accepts the ProviderConfig credentials.
+func Healthy() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeHealthy,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonHealthy,
+	}
+}
+
+// Unhealthy returns a condition that indicates the ArgoCD server failed
+// the health check for the supplied reason.
+func Unhealthy(reason xpv1.ConditionReason, msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeHealthy,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            msg,
+	}
+}
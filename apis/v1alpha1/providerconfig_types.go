@@ -17,8 +17,23 @@ type ProviderCredentials struct {
 
 // A ProviderConfigSpec defines the desired state of a ProviderConfig.
 type ProviderConfigSpec struct {
-	// ServerUrl of the argocd instance
-	ServerUrl string `json:"serverUrl"`
+	// ServerUrl of the argocd instance.
+	// Optional only if serverRef is specified; mutually exclusive with it.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^https?://[^/?#]+(/[^?#]*)?$`
+	ServerUrl string `json:"serverUrl,omitempty"`
+
+	// RootPath the ArgoCD server is served under (see argocd-server --rootpath).
+	// Detected from the argocd-cmd-params-cm ConfigMap if serverRef is used.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^/?[-._~a-zA-Z0-9/]*$`
+	RootPath string `json:"rootPath,omitempty"`
+
+	// ServerRef references the in-cluster argocd-server Service; the server
+	// url is built from the Service cluster DNS name and port. Mutually
+	// exclusive with serverUrl.
+	// +optional
+	ServerRef *ServiceReference `json:"serverRef,omitempty"`
 
 	// UserAgent request header to identify your client calls.
 	// +optional
@@ -28,20 +43,139 @@ type ProviderConfigSpec struct {
 	// +optional
 	DebugClient *bool `json:"debugClient,omitempty"`
 
+	// Insecure if true skips the verification of the ArgoCD server certificate.
+	// (Default: true, for backward compatibility)
+	// +optional
+	Insecure *bool `json:"insecure,omitempty"`
+
+	// GRPCWeb if true the ArgoCD API is called over gRPC-web, as the argocd
+	// CLI does with --grpc-web; use it for servers reachable only through
+	// HTTP/1.1 ingresses.
+	// +optional
+	GRPCWeb *bool `json:"grpcWeb,omitempty"`
+
+	// TokenProvider the backend issuing endpoint tokens: 'API' calls the
+	// ArgoCD API server, 'LocalJWT' signs them locally with the
+	// server.secretkey of argocd-secret, for when the API server is down or
+	// not yet exposed, 'Fake' issues them from memory, for demos and tests.
+	// (Default: API)
+	// +optional
+	// +kubebuilder:validation:Enum=API;LocalJWT;Fake
+	TokenProvider string `json:"tokenProvider,omitempty"`
+
+	// ArgoCDSecretRef references the argocd-secret Secret; required by the
+	// LocalJWT token provider.
+	// +optional
+	ArgoCDSecretRef *xpv1.SecretReference `json:"argocdSecretRef,omitempty"`
+
+	// CertificateAuthorityRef references the PEM encoded CA certificate
+	// used to verify the ArgoCD server certificate.
+	// +optional
+	CertificateAuthorityRef *xpv1.SecretKeySelector `json:"certificateAuthorityRef,omitempty"`
+
+	// AccountsConfigMapRef references the ArgoCD ConfigMap declaring the
+	// accounts (usually argocd-cm); if set Endpoints react to the removal
+	// or disabling of their account.
+	// +optional
+	AccountsConfigMapRef *ConfigMapReference `json:"accountsConfigMapRef,omitempty"`
+
+	// AllowedNamespaces restricts the namespaces the Endpoints using this
+	// ProviderConfig may write secrets to; entries are namespace names or
+	// shell patterns (e.g. team-*). All namespaces are allowed if empty.
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
+	// AccountPolicy restricts the tokens the Endpoints using this
+	// ProviderConfig may request.
+	// +optional
+	AccountPolicy *AccountPolicy `json:"accountPolicy,omitempty"`
+
 	// Credentials required to authenticate to this provider.
 	Credentials *ProviderCredentials `json:"credentials,omitempty"`
 }
 
+// A ServiceReference selects the argocd-server Service.
+type ServiceReference struct {
+	// Namespace of the Service.
+	Namespace string `json:"namespace"`
+
+	// Name of the Service. If not specified the Service is looked up by
+	// selector, so that it is followed even if renamed.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Selector of the Service, used if name is not specified.
+	// (Default: app.kubernetes.io/name=argocd-server)
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// PortName name of the Service port. (Default: https, then http, then
+	// the first port)
+	// +optional
+	PortName string `json:"portName,omitempty"`
+
+	// ParamsConfigMapName name of the ConfigMap holding the argocd-server
+	// parameters, used to detect a plaintext (server.insecure) setup.
+	// (Default: argocd-cmd-params-cm)
+	// +optional
+	ParamsConfigMapName string `json:"paramsConfigMapName,omitempty"`
+}
+
+// An AccountPolicy restricts the accounts tokens are issued for and the
+// lifetime of the tokens.
+type AccountPolicy struct {
+	// AllowedAccounts are the accounts tokens may be issued for; entries
+	// are names or shell patterns (e.g. ci-*). All accounts if empty.
+	// +optional
+	AllowedAccounts []string `json:"allowedAccounts,omitempty"`
+
+	// DeniedAccounts are the accounts tokens are never issued for, even
+	// if allowed; entries are names or shell patterns.
+	// +optional
+	DeniedAccounts []string `json:"deniedAccounts,omitempty"`
+
+	// MaxTokenLifetime is the longest expiresIn accepted, e.g. '720h';
+	// when set non-expiring tokens are refused too.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	MaxTokenLifetime string `json:"maxTokenLifetime,omitempty"`
+
+	// AllowNonExpiringTokens allows Endpoints without expiresIn.
+	// +optional
+	// +kubebuilder:default=true
+	AllowNonExpiringTokens *bool `json:"allowNonExpiringTokens,omitempty"`
+}
+
+// A ConfigMapReference is a reference to a ConfigMap in an arbitrary namespace.
+type ConfigMapReference struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Namespace of the ConfigMap.
+	Namespace string `json:"namespace"`
+}
+
 // A ProviderConfigStatus reflects the observed state of a ProviderConfig.
 type ProviderConfigStatus struct {
 	xpv1.ProviderConfigStatus `json:",inline"`
+
+	// Version of the ArgoCD server detected by the last health check.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// LastHealthCheckTime is the time of the last health check.
+	// +optional
+	LastHealthCheckTime *metav1.Time `json:"lastHealthCheckTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 
 // A ProviderConfig configures a Template provider.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="HEALTHY",type="string",JSONPath=".status.conditions[?(@.type=='Healthy')].status"
+// +kubebuilder:printcolumn:name="VERSION",type="string",JSONPath=".status.version"
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
-// +kubebuilder:printcolumn:name="SERVER-ADDR",type="string",JSONPath=".spec.serverAddr",priority=1
+// +kubebuilder:printcolumn:name="SERVER-URL",type="string",JSONPath=".spec.serverUrl",priority=1
 // +kubebuilder:resource:scope=Cluster,categories={crossplane,provider,argocd}
 // +kubebuilder:subresource:status
 type ProviderConfig struct {
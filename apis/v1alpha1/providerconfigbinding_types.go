@@ -0,0 +1,45 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// A ProviderConfigBindingSpec defines which ArgoCD instance, and which of
+// its accounts, the NamespacedEndpoints of a namespace may use.
+type ProviderConfigBindingSpec struct {
+	// ProviderConfigRef references the ProviderConfig of the ArgoCD
+	// instance; it must allow the namespace of the binding if it has
+	// allowedNamespaces.
+	ProviderConfigRef xpv1.Reference `json:"providerConfigRef"`
+
+	// AccountPolicy restricts the tokens the NamespacedEndpoints using this
+	// binding may request, on top of the policy of the ProviderConfig.
+	// +optional
+	AccountPolicy *AccountPolicy `json:"accountPolicy,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A ProviderConfigBinding grants the NamespacedEndpoints of its namespace
+// access to a ProviderConfig. Tenants should be allowed to create
+// NamespacedEndpoints, while bindings are managed by the cluster admins.
+// +kubebuilder:printcolumn:name="CONFIG-NAME",type="string",JSONPath=".spec.providerConfigRef.name"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:resource:scope=Namespaced,categories={crossplane,provider,argocd}
+type ProviderConfigBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProviderConfigBindingSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// ProviderConfigBindingList contains a list of ProviderConfigBinding
+type ProviderConfigBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProviderConfigBinding `json:"items"`
+}
@@ -42,7 +42,34 @@ var (
 	ProviderConfigUsageListGroupVersionKind = SchemeGroupVersion.WithKind(ProviderConfigUsageListKind)
 )
 
+// StoreConfig type metadata.
+var (
+	StoreConfigKind             = reflect.TypeOf(StoreConfig{}).Name()
+	StoreConfigGroupKind        = schema.GroupKind{Group: Group, Kind: StoreConfigKind}.String()
+	StoreConfigKindAPIVersion   = StoreConfigKind + "." + SchemeGroupVersion.String()
+	StoreConfigGroupVersionKind = SchemeGroupVersion.WithKind(StoreConfigKind)
+)
+
+// VaultConfig type metadata.
+var (
+	VaultConfigKind             = reflect.TypeOf(VaultConfig{}).Name()
+	VaultConfigGroupKind        = schema.GroupKind{Group: Group, Kind: VaultConfigKind}.String()
+	VaultConfigKindAPIVersion   = VaultConfigKind + "." + SchemeGroupVersion.String()
+	VaultConfigGroupVersionKind = SchemeGroupVersion.WithKind(VaultConfigKind)
+)
+
+// ProviderConfigBinding type metadata.
+var (
+	ProviderConfigBindingKind             = reflect.TypeOf(ProviderConfigBinding{}).Name()
+	ProviderConfigBindingGroupKind        = schema.GroupKind{Group: Group, Kind: ProviderConfigBindingKind}.String()
+	ProviderConfigBindingKindAPIVersion   = ProviderConfigBindingKind + "." + SchemeGroupVersion.String()
+	ProviderConfigBindingGroupVersionKind = SchemeGroupVersion.WithKind(ProviderConfigBindingKind)
+)
+
 func init() {
 	SchemeBuilder.Register(&ProviderConfig{}, &ProviderConfigList{})
 	SchemeBuilder.Register(&ProviderConfigUsage{}, &ProviderConfigUsageList{})
+	SchemeBuilder.Register(&ProviderConfigBinding{}, &ProviderConfigBindingList{})
+	SchemeBuilder.Register(&StoreConfig{}, &StoreConfigList{})
+	SchemeBuilder.Register(&VaultConfig{}, &VaultConfigList{})
 }
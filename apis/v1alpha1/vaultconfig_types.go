@@ -0,0 +1,92 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// VaultAuthMethod is the method used to authenticate to Vault.
+type VaultAuthMethod string
+
+// Vault auth methods.
+const (
+	VaultAuthToken      VaultAuthMethod = "Token"
+	VaultAuthKubernetes VaultAuthMethod = "Kubernetes"
+)
+
+// VaultAuth holds the settings to authenticate to Vault.
+type VaultAuth struct {
+	// Method used to authenticate to Vault.
+	// +kubebuilder:validation:Enum=Token;Kubernetes
+	Method VaultAuthMethod `json:"method"`
+
+	// TokenSecretRef references the Vault token; required by the Token method.
+	// +optional
+	TokenSecretRef *xpv1.SecretKeySelector `json:"tokenSecretRef,omitempty"`
+
+	// Kubernetes auth method settings; required by the Kubernetes method.
+	// +optional
+	Kubernetes *VaultKubernetesAuth `json:"kubernetes,omitempty"`
+}
+
+// VaultKubernetesAuth holds the settings of the Vault Kubernetes auth method.
+type VaultKubernetesAuth struct {
+	// MountPath of the Kubernetes auth method. (Default: kubernetes)
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+
+	// Role to log in with.
+	Role string `json:"role"`
+}
+
+// A VaultConfigSpec defines the desired state of a VaultConfig.
+type VaultConfigSpec struct {
+	// Address of the Vault server, e.g. https://vault.vault-system.svc:8200
+	Address string `json:"address"`
+
+	// Namespace is the Vault Enterprise namespace.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// MountPath of the KV v2 secrets engine. (Default: secret)
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+
+	// PathTemplate is the Go template of the path the endpoint secrets are
+	// written to; rendered over .Name (the Endpoint name) and .Account.
+	// (Default: krateo/argocd/{{ .Name }})
+	// +optional
+	PathTemplate string `json:"pathTemplate,omitempty"`
+
+	// Auth settings to authenticate to Vault.
+	Auth VaultAuth `json:"auth"`
+
+	// CertificateAuthorityRef references the PEM encoded CA certificate
+	// used to verify the Vault server certificate.
+	// +optional
+	CertificateAuthorityRef *xpv1.SecretKeySelector `json:"certificateAuthorityRef,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A VaultConfig configures how the provider writes endpoint secrets to Vault.
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="ADDRESS",type="string",JSONPath=".spec.address"
+// +kubebuilder:printcolumn:name="MOUNT",type="string",JSONPath=".spec.mountPath",priority=1
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,provider,argocd}
+type VaultConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VaultConfigSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// VaultConfigList contains a list of VaultConfig
+type VaultConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VaultConfig `json:"items"`
+}
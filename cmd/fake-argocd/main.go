@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/fakeargocd"
+)
+
+func main() {
+	var (
+		app           = kingpin.New(filepath.Base(os.Args[0]), "In-memory fake ArgoCD API server, for local development and demos.").DefaultEnvars()
+		debug         = app.Flag("debug", "Run with debug logging.").Short('d').Default("false").Bool()
+		addr          = app.Flag("addr", "Address to listen on.").Default(":8080").String()
+		adminPassword = app.Flag("admin-password", "Password of the admin account.").Default("admin").String()
+		rootPath      = app.Flag("root-path", "Path the API is served under, as with argocd-server --rootpath.").Default("").String()
+		tlsCert       = app.Flag("tls-cert", "TLS certificate file; plain HTTP is served if not set.").Default("").String()
+		tlsKey        = app.Flag("tls-key", "TLS private key file.").Default("").String()
+		accounts      = app.Flag("account", "Account as name[:capability,...], e.g. krateo-dashboard:apiKey. Repeatable.").Strings()
+	)
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	zl := zap.New(zap.UseDevMode(*debug))
+	log := logging.NewLogrLogger(zl.WithName("fake-argocd"))
+
+	cfg := fakeargocd.Config{
+		AdminPassword: *adminPassword,
+		RootPath:      *rootPath,
+	}
+	for _, el := range *accounts {
+		cfg.Accounts = append(cfg.Accounts, parseAccount(el))
+	}
+
+	srv := &http.Server{
+		Addr:    *addr,
+		Handler: logRequests(log, fakeargocd.NewServer(cfg)),
+	}
+
+	log.Info("Starting", "addr", *addr, "root-path", *rootPath, "accounts", len(cfg.Accounts))
+
+	var err error
+	if len(*tlsCert) > 0 {
+		err = srv.ListenAndServeTLS(*tlsCert, *tlsKey)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	kingpin.FatalIfError(err, "Cannot serve fake ArgoCD API")
+}
+
+// parseAccount parses name[:capability,...]; accounts get the apiKey
+// capability if none is specified.
+func parseAccount(s string) fakeargocd.Account {
+	res := fakeargocd.Account{Enabled: true}
+
+	name, caps, ok := strings.Cut(s, ":")
+	res.Name = strings.TrimSpace(name)
+	if !ok {
+		res.Capabilities = []string{fakeargocd.CapabilityAPIKey}
+		return res
+	}
+
+	for _, c := range strings.Split(caps, ",") {
+		if c = strings.TrimSpace(c); len(c) > 0 {
+			res.Capabilities = append(res.Capabilities, c)
+		}
+	}
+	return res
+}
+
+func logRequests(log logging.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("Request", "method", r.Method, "path", r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
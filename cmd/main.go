@@ -15,16 +15,20 @@ import (
 
 	"github.com/krateoplatformops/provider-argocd-endpoint/apis"
 	argocdtoken "github.com/krateoplatformops/provider-argocd-endpoint/internal/controller"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/features"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/webhook"
 )
 
 func main() {
 	var (
-		app              = kingpin.New(filepath.Base(os.Args[0]), "Krateo ArgoCD Endpoint Provider.").DefaultEnvars()
-		debug            = app.Flag("debug", "Run with debug logging.").Short('d').Default("true").Bool()
-		syncPeriod       = app.Flag("sync", "Controller manager sync period such as 300ms, 1.5h, or 2h45m").Short('s').Default("1h").Duration()
-		pollInterval     = app.Flag("poll", "How often individual resources will be checked for drift from the desired state").Default("5m").Duration()
-		maxReconcileRate = app.Flag("max-reconcile-rate", "The global maximum rate per second at which resources may checked for drift from the desired state.").Default("2").Int()
-		leaderElection   = app.Flag("leader-election", "Use leader election for the controller manager.").Short('l').Default("false").OverrideDefaultFromEnvar("LEADER_ELECTION").Bool()
+		app                        = kingpin.New(filepath.Base(os.Args[0]), "Krateo ArgoCD Endpoint Provider.").DefaultEnvars()
+		debug                      = app.Flag("debug", "Run with debug logging.").Short('d').Default("true").Bool()
+		syncPeriod                 = app.Flag("sync", "Controller manager sync period such as 300ms, 1.5h, or 2h45m").Short('s').Default("1h").Duration()
+		pollInterval               = app.Flag("poll", "How often individual resources will be checked for drift from the desired state").Default("5m").Duration()
+		maxReconcileRate           = app.Flag("max-reconcile-rate", "The global maximum rate per second at which resources may checked for drift from the desired state.").Default("2").Int()
+		leaderElection             = app.Flag("leader-election", "Use leader election for the controller manager.").Short('l').Default("false").OverrideDefaultFromEnvar("LEADER_ELECTION").Bool()
+		enableExternalSecretStores = app.Flag("enable-external-secret-stores", "Enable support for ExternalSecretStores.").Default("false").OverrideDefaultFromEnvar("ENABLE_EXTERNAL_SECRET_STORES").Bool()
+		webhookCertDir             = app.Flag("webhook-tls-cert-dir", "The directory of TLS certificate that will be used by the webhook server. Webhooks are disabled if not set.").Default("").OverrideDefaultFromEnvar("WEBHOOK_TLS_CERT_DIR").String()
 	)
 	kingpin.MustParse(app.Parse(os.Args[1:]))
 
@@ -47,6 +51,7 @@ func main() {
 		LeaderElectionID:   "crossplane-leader-election-provider-argocd-endpoint",
 		SyncPeriod:         syncPeriod,
 		MetricsBindAddress: ":9090",
+		CertDir:            *webhookCertDir,
 	})
 	kingpin.FatalIfError(err, "Cannot create controller manager")
 
@@ -60,6 +65,14 @@ func main() {
 		Features:                &feature.Flags{},
 	}
 
+	if *enableExternalSecretStores {
+		o.Features.Enable(features.EnableAlphaExternalSecretStores)
+		log.Info("Alpha feature enabled", "flag", features.EnableAlphaExternalSecretStores)
+	}
+
 	kingpin.FatalIfError(argocdtoken.Setup(mgr, o), "Cannot setup ArgoCD Endpoint controller")
+	if len(*webhookCertDir) > 0 {
+		kingpin.FatalIfError(webhook.Setup(mgr, log), "Cannot setup ArgoCD Endpoint webhooks")
+	}
 	kingpin.FatalIfError(mgr.Start(ctrl.SetupSignalHandler()), "Cannot start controller manager")
 }
@@ -0,0 +1,207 @@
+// Package argocdtest provides an in-process stand-in for the ArgoCD API,
+// backed by the fake ArgoCD server, with injectable failures.
+package argocdtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/fakeargocd"
+)
+
+// Default credentials of the stand-in server.
+const (
+	AdminPassword = "s3cr3t"
+	Account       = "krateo-dashboard"
+)
+
+// Routes of the ArgoCD API; '*' matches a single path segment. The
+// matching gRPC-web calls share the same routes.
+const (
+	RouteVersion     = "GET /api/version"
+	RouteSession     = "POST /api/v1/session"
+	RouteGetAccount  = "GET /api/v1/account/*"
+	RouteCreateToken = "POST /api/v1/account/*/token"
+	RouteDeleteToken = "DELETE /api/v1/account/*/token/*"
+)
+
+// A Failure alters the response of a route.
+type Failure struct {
+	// Status if not zero is returned instead of calling the API.
+	Status int
+	// Body returned with Status.
+	Body string
+	// Delay before the response.
+	Delay time.Duration
+	// Times the failure is injected; always if zero.
+	Times int
+}
+
+// Unauthorized returns a failure answering 401, as ArgoCD does for an
+// invalid session.
+func Unauthorized() Failure {
+	return Failure{Status: http.StatusUnauthorized, Body: `{"error":"invalid session","code":16,"message":"invalid session"}`}
+}
+
+// NotFound returns a failure answering 404.
+func NotFound() Failure {
+	return Failure{Status: http.StatusNotFound, Body: `{"error":"not found","code":5,"message":"not found"}`}
+}
+
+// TooManyRequests returns a failure answering 429.
+func TooManyRequests() Failure {
+	return Failure{Status: http.StatusTooManyRequests, Body: `{"error":"too many requests","code":8,"message":"too many requests"}`}
+}
+
+// MalformedJSON returns a failure answering 200 with an invalid JSON body.
+func MalformedJSON() Failure {
+	return Failure{Status: http.StatusOK, Body: `{"token": `}
+}
+
+// Slow returns a failure delaying the response by the supplied duration.
+func Slow(d time.Duration) Failure {
+	return Failure{Delay: d}
+}
+
+// Server is an ArgoCD stand-in served over HTTP.
+type Server struct {
+	*httptest.Server
+
+	// Fake is the in-memory ArgoCD API.
+	Fake *fakeargocd.Server
+
+	rootPath string
+
+	mu       sync.Mutex
+	failures map[string]*Failure
+	calls    map[string]int
+}
+
+// NewServer starts a new ArgoCD stand-in with the admin password
+// AdminPassword and the Account account, with the apiKey capability.
+func NewServer() *Server {
+	return NewServerWithConfig(fakeargocd.Config{
+		AdminPassword: AdminPassword,
+		Accounts: []fakeargocd.Account{
+			{Name: Account, Enabled: true, Capabilities: []string{fakeargocd.CapabilityAPIKey}},
+		},
+	})
+}
+
+// NewServerWithConfig starts a new ArgoCD stand-in with the supplied configuration.
+func NewServerWithConfig(cfg fakeargocd.Config) *Server {
+	s := &Server{
+		Fake:     fakeargocd.NewServer(cfg),
+		rootPath: strings.Trim(cfg.RootPath, "/"),
+		failures: map[string]*Failure{},
+		calls:    map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Fail injects the supplied failure in the supplied route.
+func (s *Server) Fail(route string, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[route] = &f
+}
+
+// Reset removes all the injected failures.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = map[string]*Failure{}
+}
+
+// Calls returns how many times the supplied route has been called.
+func (s *Server) Calls(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[route]
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	route := s.route(r)
+
+	s.mu.Lock()
+	s.calls[route]++
+	f, ok := s.failures[route]
+	var fail Failure
+	if ok {
+		fail = *f
+		if f.Times > 0 {
+			if f.Times--; f.Times == 0 {
+				delete(s.failures, route)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if fail.Delay > 0 {
+		select {
+		case <-time.After(fail.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if fail.Status != 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(fail.Status)
+		_, _ = w.Write([]byte(fail.Body))
+		return
+	}
+
+	s.Fake.ServeHTTP(w, r)
+}
+
+// grpcWebRoutes are the routes of the gRPC-web methods.
+var grpcWebRoutes = map[string]string{
+	fakeargocd.MethodVersion:            RouteVersion,
+	fakeargocd.MethodSessionCreate:      RouteSession,
+	fakeargocd.MethodAccountGet:         RouteGetAccount,
+	fakeargocd.MethodAccountCreateToken: RouteCreateToken,
+	fakeargocd.MethodAccountDeleteToken: RouteDeleteToken,
+}
+
+// route returns the route matching the supplied request, or the request
+// method and path if none matches.
+func (s *Server) route(r *http.Request) string {
+	p := strings.TrimPrefix(strings.Trim(r.URL.Path, "/"), s.rootPath)
+	p = strings.Trim(p, "/")
+
+	if route, ok := grpcWebRoutes[p]; ok && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc-web") {
+		return route
+	}
+
+	parts := strings.Split(p, "/")
+
+	for _, route := range []string{RouteVersion, RouteSession, RouteGetAccount, RouteCreateToken, RouteDeleteToken} {
+		method, path, _ := strings.Cut(route, " ")
+		if method != r.Method {
+			continue
+		}
+
+		want := strings.Split(strings.Trim(path, "/"), "/")
+		if len(want) != len(parts) {
+			continue
+		}
+
+		ok := true
+		for i := range want {
+			if want[i] != "*" && want[i] != parts[i] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return route
+		}
+	}
+
+	return r.Method + " " + r.URL.Path
+}
@@ -0,0 +1,45 @@
+package accounts
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// TokenClaims are the ArgoCD account token claims relevant to the provider.
+type TokenClaims struct {
+	ID        string `json:"jti,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// ExpirationTime returns the token expiration time; the zero value
+// means that the token never expires.
+func (c TokenClaims) ExpirationTime() time.Time {
+	if c.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.ExpiresAt, 0).UTC()
+}
+
+// ParseTokenClaims decodes the claims of an ArgoCD token.
+// The token signature is NOT verified.
+func ParseTokenClaims(token string) (TokenClaims, error) {
+	var res TokenClaims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return res, errors.New("malformed argocd token")
+	}
+
+	bin, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return res, err
+	}
+
+	err = json.Unmarshal(bin, &res)
+	return res, err
+}
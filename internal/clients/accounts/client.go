@@ -2,7 +2,9 @@ package accounts
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,32 +12,92 @@ import (
 	"log"
 	"net/http"
 	"net/http/httputil"
+	"time"
 )
 
 const (
 	defaultUserAgent = "Krateo Platfomops"
+	defaultTimeout   = 30 * time.Second
 )
 
 // Login do a login with username and password credentials and returns the auth token.
-func Login(opts *TokenProviderOptions, user, pass string) (string, error) {
+func Login(ctx context.Context, opts *TokenProviderOptions, user, pass string) (string, error) {
 	cli, err := NewTokenProvider(opts)
 	if err != nil {
 		return "", err
 	}
 
-	return cli.CreateSession(user, pass)
+	return cli.CreateSession(ctx, user, pass)
 }
 
 // GenerateToken generate a token for the account with the specified name.
-// expiresIn specify the duration before the token will expire; by default: no expiration.
-func GenerateToken(opts *TokenProviderOptions, name string, expiresIn int64) (string, error) {
+// id is the token id, generated by the server if empty; expiresIn specify
+// the seconds before the token will expire; by default: no expiration.
+func GenerateToken(ctx context.Context, opts *TokenProviderOptions, name, id string, expiresIn int64) (string, error) {
 	cli, err := NewTokenProvider(opts)
 	if err != nil {
 		return "", err
 	}
 	cli.SetAuthToken(opts.AuthToken)
 
-	return cli.CreateTokenForAccount(name)
+	return cli.CreateTokenForAccount(ctx, name, id, expiresIn)
+}
+
+// ListTokens returns the tokens of the account with the specified name.
+func ListTokens(ctx context.Context, opts *TokenProviderOptions, name string) ([]Token, error) {
+	cli, err := NewTokenProvider(opts)
+	if err != nil {
+		return nil, err
+	}
+	cli.SetAuthToken(opts.AuthToken)
+
+	return cli.ListTokens(ctx, name)
+}
+
+// DeleteToken deletes the token with the specified id of the account with the specified name.
+func DeleteToken(ctx context.Context, opts *TokenProviderOptions, name, id string) error {
+	cli, err := NewTokenProvider(opts)
+	if err != nil {
+		return err
+	}
+	cli.SetAuthToken(opts.AuthToken)
+
+	return cli.DeleteToken(ctx, name, id)
+}
+
+// GetVersion returns the version of the ArgoCD server.
+func GetVersion(ctx context.Context, opts *TokenProviderOptions) (string, error) {
+	cli, err := NewTokenProvider(opts)
+	if err != nil {
+		return "", err
+	}
+
+	return cli.Version(ctx)
+}
+
+// notFoundError is returned when the account or the token does not exist.
+type notFoundError struct {
+	msg string
+}
+
+func (e *notFoundError) Error() string {
+	return e.msg
+}
+
+// IsNotFound returns true if the supplied error reports that the account or
+// the token does not exist.
+func IsNotFound(err error) bool {
+	var nf *notFoundError
+	return errors.As(err, &nf)
+}
+
+// requestFailed returns the error of a failed request with the supplied
+// description.
+func requestFailed(desc string, res *http.Response) error {
+	if res.StatusCode == http.StatusNotFound {
+		return &notFoundError{msg: fmt.Sprintf("%s request failed: %s", desc, res.Status)}
+	}
+	return fmt.Errorf("%s request failed: %s", desc, res.Status)
 }
 
 // TokenProviderOptions hold url, auth token for the API client.
@@ -44,17 +106,40 @@ type TokenProviderOptions struct {
 	UserAgent   string
 	AuthToken   string
 	DebugClient bool
+	Insecure    bool
+	CACert      []byte
+	GRPCWeb     bool
+	Backend     Backend
+	SecretStore SecretStore
+	// Timeout of each request; 30s if zero.
+	Timeout time.Duration
+}
+
+// Token is an ArgoCD account token.
+type Token struct {
+	ID        string
+	IssuedAt  int64
+	ExpiresAt int64
 }
 
 // TokenProvider defines an interface for interaction with an Argo CD server.
+// Requests are canceled along with the supplied context.
 type TokenProvider interface {
-	CreateSession(username, password string) (string, error)
-	CreateTokenForAccount(name string) (string, error)
+	CreateSession(ctx context.Context, username, password string) (string, error)
+	CreateTokenForAccount(ctx context.Context, name, id string, expiresIn int64) (string, error)
+	ListTokens(ctx context.Context, name string) ([]Token, error)
+	DeleteToken(ctx context.Context, name, id string) error
 	SetAuthToken(token string)
+	Version(ctx context.Context) (string, error)
 }
 
-// NewTokenProvider creates a new ArgoCD token provider from a set of config options.
-func NewTokenProvider(opts *TokenProviderOptions) (TokenProvider, error) {
+func init() {
+	Register(BackendAPI, Capabilities{Expiry: true, Revoke: true, List: true, Session: true}, newAPITokenProvider)
+}
+
+// newAPITokenProvider creates a token provider calling the ArgoCD API,
+// over REST or gRPC-web.
+func newAPITokenProvider(opts *TokenProviderOptions) (TokenProvider, error) {
 	var res tokenProvider
 
 	if opts.UserAgent != "" {
@@ -63,19 +148,38 @@ func NewTokenProvider(opts *TokenProviderOptions) (TokenProvider, error) {
 		res.userAgent = defaultUserAgent
 	}
 
-	if opts.ServerUrl != "" {
-		res.serverAddr = opts.ServerUrl
-	}
 	// Make sure we got the server address and auth token from somewhere
-	if res.serverAddr == "" {
+	if opts.ServerUrl == "" {
 		return nil, errors.New("unspecified server url for Argo CD")
 	}
 
+	addr, err := NormalizeServerURL(opts.ServerUrl, "")
+	if err != nil {
+		return nil, err
+	}
+	res.serverAddr = addr
+
 	res.debugClient = opts.DebugClient
 
-	res.httpClient = &http.Client{}
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.Insecure}
+	if len(opts.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(opts.CACert) {
+			return nil, errors.New("invalid CA certificate for Argo CD")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	res.httpClient = &http.Client{Timeout: defaultTimeout}
+	if opts.Timeout > 0 {
+		res.httpClient.Timeout = opts.Timeout
+	}
 	res.httpClient.Transport = &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		TLSClientConfig: tlsConfig,
+	}
+
+	if opts.GRPCWeb {
+		return &grpcWebTokenProvider{&res}, nil
 	}
 
 	return &res, nil
@@ -93,7 +197,7 @@ func (tp *tokenProvider) SetAuthToken(token string) {
 	tp.authToken = token
 }
 
-func (tp *tokenProvider) CreateSession(user, pass string) (string, error) {
+func (tp *tokenProvider) CreateSession(ctx context.Context, user, pass string) (string, error) {
 	data := map[string]string{
 		"username": user,
 		"password": pass,
@@ -104,9 +208,9 @@ func (tp *tokenProvider) CreateSession(user, pass string) (string, error) {
 		return "", err
 	}
 
-	url := fmt.Sprintf("%s/api/v1/session", tp.serverAddr)
+	url := joinURL(tp.serverAddr, "api", "v1", "session")
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(bin))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(bin))
 	if err != nil {
 		return "", err
 	}
@@ -143,10 +247,22 @@ func (tp *tokenProvider) CreateSession(user, pass string) (string, error) {
 	return response["token"], nil
 }
 
-func (tp *tokenProvider) CreateTokenForAccount(name string) (string, error) {
-	url := fmt.Sprintf("%s/api/v1/account/%s/token", tp.serverAddr, name)
+func (tp *tokenProvider) CreateTokenForAccount(ctx context.Context, name, id string, expiresIn int64) (string, error) {
+	data := map[string]interface{}{
+		"expiresIn": expiresIn,
+	}
+	if len(id) > 0 {
+		data["id"] = id
+	}
+
+	bin, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
 
-	req, err := http.NewRequest(http.MethodPost, url, nil)
+	url := joinURL(tp.serverAddr, "api", "v1", "account", name, "token")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(bin))
 	if err != nil {
 		return "", err
 	}
@@ -184,6 +300,135 @@ func (tp *tokenProvider) CreateTokenForAccount(name string) (string, error) {
 	return response["token"], nil
 }
 
+func (tp *tokenProvider) ListTokens(ctx context.Context, name string) ([]Token, error) {
+	url := joinURL(tp.serverAddr, "api", "v1", "account", name)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tp.authToken))
+
+	if tp.debugClient {
+		debug(httputil.DumpRequestOut(req, true))
+	}
+
+	res, err := tp.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if tp.debugClient {
+		debug(httputil.DumpResponse(res, true))
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, requestFailed("get argocd account", res)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// grpc-gateway encodes int64 values as strings
+	var response struct {
+		Tokens []struct {
+			ID        string      `json:"id"`
+			IssuedAt  json.Number `json:"issuedAt"`
+			ExpiresAt json.Number `json:"expiresAt"`
+		} `json:"tokens"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]Token, 0, len(response.Tokens))
+	for _, el := range response.Tokens {
+		iat, _ := el.IssuedAt.Int64()
+		exp, _ := el.ExpiresAt.Int64()
+		tokens = append(tokens, Token{ID: el.ID, IssuedAt: iat, ExpiresAt: exp})
+	}
+
+	return tokens, nil
+}
+
+func (tp *tokenProvider) DeleteToken(ctx context.Context, name, id string) error {
+	url := joinURL(tp.serverAddr, "api", "v1", "account", name, "token", id)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tp.authToken))
+
+	if tp.debugClient {
+		debug(httputil.DumpRequestOut(req, true))
+	}
+
+	res, err := tp.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if tp.debugClient {
+		debug(httputil.DumpResponse(res, true))
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return requestFailed("delete argocd account token", res)
+	}
+
+	return nil
+}
+
+func (tp *tokenProvider) Version(ctx context.Context) (string, error) {
+	url := joinURL(tp.serverAddr, "api", "version")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", tp.userAgent)
+
+	if tp.debugClient {
+		debug(httputil.DumpRequestOut(req, true))
+	}
+
+	res, err := tp.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if tp.debugClient {
+		debug(httputil.DumpResponse(res, true))
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get argocd version request failed: %s", res.Status)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+
+	ver, ok := response["Version"].(string)
+	if !ok || len(ver) == 0 {
+		return "", errors.New("argocd version not found in response")
+	}
+
+	return ver, nil
+}
+
 func debug(data []byte, err error) {
 	if err == nil {
 		fmt.Printf("%s\n\n", data)
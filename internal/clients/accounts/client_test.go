@@ -0,0 +1,423 @@
+package accounts
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/argocdtest"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/fakeargocd"
+)
+
+// newArgoCD starts an ArgoCD stand-in with, besides argocdtest.Account, a
+// disabled account and one without the apiKey capability.
+func newArgoCD(t *testing.T, rootPath string) *argocdtest.Server {
+	t.Helper()
+
+	srv := argocdtest.NewServerWithConfig(fakeargocd.Config{
+		AdminPassword: argocdtest.AdminPassword,
+		RootPath:      rootPath,
+		Accounts: []fakeargocd.Account{
+			{Name: argocdtest.Account, Enabled: true, Capabilities: []string{fakeargocd.CapabilityAPIKey}},
+			{Name: "ci", Enabled: false, Capabilities: []string{fakeargocd.CapabilityAPIKey}},
+			{Name: "viewer", Enabled: true, Capabilities: []string{fakeargocd.CapabilityLogin}},
+		},
+	})
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestLogin(t *testing.T) {
+	cases := map[string]struct {
+		grpcWeb  bool
+		user     string
+		pass     string
+		failure  *argocdtest.Failure
+		timeout  time.Duration
+		deadline time.Duration
+		wantErr  string
+	}{
+		"Admin": {
+			user: "admin",
+			pass: argocdtest.AdminPassword,
+		},
+		"AdminGRPCWeb": {
+			grpcWeb: true,
+			user:    "admin",
+			pass:    argocdtest.AdminPassword,
+		},
+		"WrongPassword": {
+			user:    "admin",
+			pass:    "wrong",
+			wantErr: "401 Unauthorized",
+		},
+		"WrongPasswordGRPCWeb": {
+			grpcWeb: true,
+			user:    "admin",
+			pass:    "wrong",
+			wantErr: "grpc-status 16",
+		},
+		"NotAdmin": {
+			user:    "viewer",
+			pass:    argocdtest.AdminPassword,
+			wantErr: "401 Unauthorized",
+		},
+		"NotFound": {
+			user:    "admin",
+			pass:    argocdtest.AdminPassword,
+			failure: failure(argocdtest.NotFound()),
+			wantErr: "404 Not Found",
+		},
+		"TooManyRequests": {
+			user:    "admin",
+			pass:    argocdtest.AdminPassword,
+			failure: failure(argocdtest.TooManyRequests()),
+			wantErr: "429 Too Many Requests",
+		},
+		"MalformedJSON": {
+			user:    "admin",
+			pass:    argocdtest.AdminPassword,
+			failure: failure(argocdtest.MalformedJSON()),
+			wantErr: "unexpected end of JSON input",
+		},
+		"Slow": {
+			user:    "admin",
+			pass:    argocdtest.AdminPassword,
+			failure: failure(argocdtest.Slow(time.Second)),
+			timeout: 50 * time.Millisecond,
+			wantErr: "Client.Timeout exceeded",
+		},
+		"ContextDeadline": {
+			user:     "admin",
+			pass:     argocdtest.AdminPassword,
+			failure:  failure(argocdtest.Slow(time.Second)),
+			deadline: 50 * time.Millisecond,
+			wantErr:  "context deadline exceeded",
+		},
+		"ContextDeadlineGRPCWeb": {
+			grpcWeb:  true,
+			user:     "admin",
+			pass:     argocdtest.AdminPassword,
+			failure:  failure(argocdtest.Slow(time.Second)),
+			deadline: 50 * time.Millisecond,
+			wantErr:  "context deadline exceeded",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := newArgoCD(t, "")
+			if tc.failure != nil {
+				srv.Fail(argocdtest.RouteSession, *tc.failure)
+			}
+
+			ctx := context.Background()
+			if tc.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.deadline)
+				defer cancel()
+			}
+
+			opts := &TokenProviderOptions{ServerUrl: srv.URL, GRPCWeb: tc.grpcWeb, Timeout: tc.timeout}
+			token, err := Login(ctx, opts, tc.user, tc.pass)
+			if len(tc.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Login(...): want error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Login(...): %v", err)
+			}
+
+			claims, err := ParseTokenClaims(token)
+			if err != nil {
+				t.Fatalf("ParseTokenClaims(...): %v", err)
+			}
+			if claims.Subject != "admin" {
+				t.Errorf("Login(...): want subject admin, got %q", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	cases := map[string]struct {
+		grpcWeb   bool
+		rootPath  string
+		account   string
+		id        string
+		expiresIn int64
+		failure   *argocdtest.Failure
+		timeout   time.Duration
+		wantErr   string
+	}{
+		"ServerID": {
+			account: argocdtest.Account,
+		},
+		"IDAndExpiry": {
+			account:   argocdtest.Account,
+			id:        "my-token",
+			expiresIn: 3600,
+		},
+		"RootPath": {
+			rootPath: "/argocd",
+			account:  argocdtest.Account,
+			id:       "my-token",
+		},
+		"GRPCWeb": {
+			grpcWeb:   true,
+			account:   argocdtest.Account,
+			expiresIn: 3600,
+		},
+		"GRPCWebRootPath": {
+			grpcWeb:  true,
+			rootPath: "/argocd",
+			account:  argocdtest.Account,
+			id:       "my-token",
+		},
+		"UnknownAccount": {
+			account: "nobody",
+			wantErr: "404 Not Found",
+		},
+		"UnknownAccountGRPCWeb": {
+			grpcWeb: true,
+			account: "nobody",
+			wantErr: "grpc-status 5",
+		},
+		"DisabledAccount": {
+			account: "ci",
+			wantErr: "400 Bad Request",
+		},
+		"NoAPIKey": {
+			account: "viewer",
+			wantErr: "403 Forbidden",
+		},
+		"NoAPIKeyGRPCWeb": {
+			grpcWeb: true,
+			account: "viewer",
+			wantErr: "grpc-status 7",
+		},
+		"TooManyRequests": {
+			account: argocdtest.Account,
+			failure: failure(argocdtest.TooManyRequests()),
+			wantErr: "429 Too Many Requests",
+		},
+		"MalformedJSON": {
+			account: argocdtest.Account,
+			failure: failure(argocdtest.MalformedJSON()),
+			wantErr: "unexpected end of JSON input",
+		},
+		"Slow": {
+			account: argocdtest.Account,
+			failure: failure(argocdtest.Slow(time.Second)),
+			timeout: 50 * time.Millisecond,
+			wantErr: "Client.Timeout exceeded",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := newArgoCD(t, tc.rootPath)
+
+			opts := &TokenProviderOptions{ServerUrl: srv.URL + tc.rootPath + "/", GRPCWeb: tc.grpcWeb, Timeout: tc.timeout}
+			session, err := Login(context.Background(), opts, "admin", argocdtest.AdminPassword)
+			if err != nil {
+				t.Fatalf("Login(...): %v", err)
+			}
+			opts.AuthToken = session
+
+			if tc.failure != nil {
+				srv.Fail(argocdtest.RouteCreateToken, *tc.failure)
+			}
+
+			token, err := GenerateToken(context.Background(), opts, tc.account, tc.id, tc.expiresIn)
+			if len(tc.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("GenerateToken(...): want error containing %q, got %v", tc.wantErr, err)
+				}
+				if got := srv.Calls(argocdtest.RouteCreateToken); got != 1 {
+					t.Errorf("GenerateToken(...): want 1 call, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateToken(...): %v", err)
+			}
+
+			claims, err := ParseTokenClaims(token)
+			if err != nil {
+				t.Fatalf("ParseTokenClaims(...): %v", err)
+			}
+			if want := tc.account + ":apiKey"; claims.Subject != want {
+				t.Errorf("GenerateToken(...): want subject %q, got %q", want, claims.Subject)
+			}
+			if len(tc.id) > 0 && claims.ID != tc.id {
+				t.Errorf("GenerateToken(...): want id %q, got %q", tc.id, claims.ID)
+			}
+			if tc.expiresIn == 0 && claims.ExpiresAt != 0 {
+				t.Errorf("GenerateToken(...): want no expiration, got %d", claims.ExpiresAt)
+			}
+			if got := claims.ExpiresAt - claims.IssuedAt; tc.expiresIn > 0 && got != tc.expiresIn {
+				t.Errorf("GenerateToken(...): want expiration in %ds, got %ds", tc.expiresIn, got)
+			}
+
+			got, err := ListTokens(context.Background(), opts, tc.account)
+			if err != nil {
+				t.Fatalf("ListTokens(...): %v", err)
+			}
+			want := []Token{{ID: claims.ID, IssuedAt: claims.IssuedAt, ExpiresAt: claims.ExpiresAt}}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("ListTokens(...): -want, +got:\n%s", diff)
+			}
+
+			if _, err := GenerateToken(context.Background(), opts, tc.account, claims.ID, 0); err == nil {
+				t.Errorf("GenerateToken(...) with a duplicated id: want error, got nil")
+			}
+
+			if err := DeleteToken(context.Background(), opts, tc.account, claims.ID); err != nil {
+				t.Fatalf("DeleteToken(...): %v", err)
+			}
+			if diff := cmp.Diff([]fakeargocd.Token{}, srv.Fake.Tokens(tc.account), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("DeleteToken(...): -want, +got:\n%s", diff)
+			}
+			if err := DeleteToken(context.Background(), opts, tc.account, claims.ID); !IsNotFound(err) {
+				t.Errorf("DeleteToken(...) twice: want not found error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateTokenTransientFailure(t *testing.T) {
+	srv := newArgoCD(t, "")
+
+	opts := &TokenProviderOptions{ServerUrl: srv.URL}
+	token, err := Login(context.Background(), opts, "admin", argocdtest.AdminPassword)
+	if err != nil {
+		t.Fatalf("Login(...): %v", err)
+	}
+	opts.AuthToken = token
+
+	f := argocdtest.TooManyRequests()
+	f.Times = 1
+	srv.Fail(argocdtest.RouteCreateToken, f)
+
+	if _, err := GenerateToken(context.Background(), opts, argocdtest.Account, "", 0); err == nil {
+		t.Errorf("GenerateToken(...): want error, got nil")
+	}
+
+	if _, err := GenerateToken(context.Background(), opts, argocdtest.Account, "", 0); err != nil {
+		t.Errorf("GenerateToken(...): want no error, got %v", err)
+	}
+
+	if got := len(srv.Fake.Tokens(argocdtest.Account)); got != 1 {
+		t.Errorf("Tokens(...): want 1 token, got %d", got)
+	}
+}
+
+func TestUnauthenticated(t *testing.T) {
+	cases := map[string]struct {
+		grpcWeb bool
+		wantErr string
+	}{
+		"REST":    {wantErr: "401"},
+		"GRPCWeb": {grpcWeb: true, wantErr: "grpc-status 16"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := newArgoCD(t, "")
+			opts := &TokenProviderOptions{ServerUrl: srv.URL, GRPCWeb: tc.grpcWeb, AuthToken: "forged"}
+
+			if _, err := GenerateToken(context.Background(), opts, argocdtest.Account, "", 0); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("GenerateToken(...) with a forged session: want error containing %q, got %v", tc.wantErr, err)
+			}
+			if _, err := ListTokens(context.Background(), opts, argocdtest.Account); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("ListTokens(...) with a forged session: want error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	for name, grpcWeb := range map[string]bool{"REST": false, "GRPCWeb": true} {
+		t.Run(name, func(t *testing.T) {
+			srv := newArgoCD(t, "")
+
+			opts := &TokenProviderOptions{ServerUrl: srv.URL, GRPCWeb: grpcWeb}
+			token, err := Login(context.Background(), opts, "admin", argocdtest.AdminPassword)
+			if err != nil {
+				t.Fatalf("Login(...): %v", err)
+			}
+			opts.AuthToken = token
+
+			if _, err := ListTokens(context.Background(), opts, "removed"); !IsNotFound(err) {
+				t.Errorf("ListTokens(...) of an unknown account: want not found error, got %v", err)
+			}
+			if err := DeleteToken(context.Background(), opts, argocdtest.Account, "unknown"); !IsNotFound(err) {
+				t.Errorf("DeleteToken(...) of an unknown token: want not found error, got %v", err)
+			}
+
+			opts.AuthToken = "forged"
+			if _, err := ListTokens(context.Background(), opts, argocdtest.Account); err == nil || IsNotFound(err) {
+				t.Errorf("ListTokens(...) with a forged session: want an error other than not found, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	for name, grpcWeb := range map[string]bool{"REST": false, "GRPCWeb": true} {
+		t.Run(name, func(t *testing.T) {
+			srv := newArgoCD(t, "/argocd")
+			opts := &TokenProviderOptions{ServerUrl: srv.URL + "/argocd/", GRPCWeb: grpcWeb}
+
+			got, err := GetVersion(context.Background(), opts)
+			if err != nil {
+				t.Fatalf("GetVersion(...): %v", err)
+			}
+			if got != "v2.4.7+fake" {
+				t.Errorf("GetVersion(...): want v2.4.7+fake, got %q", got)
+			}
+		})
+	}
+}
+
+func failure(f argocdtest.Failure) *argocdtest.Failure {
+	return &f
+}
+
+func TestNormalizeServerURL(t *testing.T) {
+	cases := map[string]struct {
+		server   string
+		rootPath string
+		want     string
+		wantErr  bool
+	}{
+		"TrailingSlash":   {server: "https://argocd.example.com/", want: "https://argocd.example.com"},
+		"RootPath":        {server: "https://argocd.example.com//", rootPath: "argocd/", want: "https://argocd.example.com/argocd"},
+		"PathAndRootPath": {server: "http://example.com/tools", rootPath: "/argocd", want: "http://example.com/tools/argocd"},
+		"Port":            {server: "https://argocd-server.argocd.svc:443", want: "https://argocd-server.argocd.svc:443"},
+		"NoScheme":        {server: "argocd.example.com", wantErr: true},
+		"BadScheme":       {server: "ftp://argocd.example.com", wantErr: true},
+		"NoHost":          {server: "https://", wantErr: true},
+		"Query":           {server: "https://argocd.example.com?x=1", wantErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NormalizeServerURL(tc.server, tc.rootPath)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("NormalizeServerURL(...): want error %t, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("NormalizeServerURL(...): want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
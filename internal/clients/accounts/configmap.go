@@ -0,0 +1,61 @@
+package accounts
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	adminAccount = "admin"
+
+	capabilityAPIKey = "apiKey"
+)
+
+// AccountState is the state of an account as declared in the argocd-cm ConfigMap.
+type AccountState string
+
+// Account states.
+const (
+	// AccountAvailable the account exists, is enabled and can issue tokens.
+	AccountAvailable AccountState = "Available"
+	// AccountRemoved the account is not declared.
+	AccountRemoved AccountState = "Removed"
+	// AccountDisabled the account is declared but disabled.
+	AccountDisabled AccountState = "Disabled"
+	// AccountNoAPIKey the account lacks the apiKey capability.
+	AccountNoAPIKey AccountState = "NoAPIKey"
+)
+
+// AccountStateFromConfigMap returns the state of the named account from
+// the data of the argocd-cm ConfigMap, see:
+// https://argo-cd.readthedocs.io/en/stable/operator-manual/user-management/
+func AccountStateFromConfigMap(data map[string]string, name string) AccountState {
+	caps, declared := data["accounts."+name]
+
+	enabled := "true"
+	if name == adminAccount {
+		// The admin account is built-in and has no capabilities by default.
+		declared = true
+		if v, ok := data["admin.enabled"]; ok {
+			enabled = v
+		}
+	} else if v, ok := data["accounts."+name+".enabled"]; ok {
+		enabled = v
+	}
+
+	if !declared {
+		return AccountRemoved
+	}
+
+	if ok, err := strconv.ParseBool(strings.TrimSpace(enabled)); err == nil && !ok {
+		return AccountDisabled
+	}
+
+	for _, c := range strings.Split(caps, ",") {
+		if strings.TrimSpace(c) == capabilityAPIKey {
+			return AccountAvailable
+		}
+	}
+
+	return AccountNoAPIKey
+}
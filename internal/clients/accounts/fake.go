@@ -0,0 +1,100 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// fakeSigningKey signs the tokens issued by the Fake backend.
+var fakeSigningKey = []byte("provider-argocd-endpoint-fake")
+
+func init() {
+	Register(BackendFake, Capabilities{Expiry: true, Revoke: true, List: true}, newFakeTokenProvider)
+}
+
+// fakeTokens holds the tokens issued by the Fake backend, per account;
+// they live as long as the process.
+var fakeTokens = struct {
+	sync.Mutex
+	accounts map[string][]Token
+}{accounts: map[string][]Token{}}
+
+// newFakeTokenProvider creates a token provider that issues tokens from
+// memory, for demos and tests without any ArgoCD.
+func newFakeTokenProvider(_ *TokenProviderOptions) (TokenProvider, error) {
+	return &fakeTokenProvider{now: time.Now}, nil
+}
+
+type fakeTokenProvider struct {
+	now func() time.Time
+}
+
+func (tp *fakeTokenProvider) SetAuthToken(string) {}
+
+func (tp *fakeTokenProvider) CreateSession(context.Context, string, string) (string, error) {
+	return "fake-session", nil
+}
+
+func (tp *fakeTokenProvider) CreateTokenForAccount(_ context.Context, name, id string, expiresIn int64) (string, error) {
+	if len(id) == 0 {
+		id = string(uuid.NewUUID())
+	}
+
+	now := tp.now().UTC()
+	claims := jwtClaims{
+		Issuer:    tokenIssuer,
+		Subject:   fmt.Sprintf(apiKeySubjectFmt, name),
+		NotBefore: now.Unix(),
+		IssuedAt:  now.Unix(),
+		ID:        id,
+	}
+	if expiresIn > 0 {
+		claims.ExpiresAt = now.Add(time.Duration(expiresIn) * time.Second).Unix()
+	}
+
+	fakeTokens.Lock()
+	defer fakeTokens.Unlock()
+
+	for _, el := range fakeTokens.accounts[name] {
+		if el.ID == id {
+			return "", fmt.Errorf("account '%s' already has a token with id '%s'", name, id)
+		}
+	}
+
+	token, err := signHS256(claims, fakeSigningKey)
+	if err != nil {
+		return "", err
+	}
+
+	fakeTokens.accounts[name] = append(fakeTokens.accounts[name], Token{ID: id, IssuedAt: claims.IssuedAt, ExpiresAt: claims.ExpiresAt})
+	return token, nil
+}
+
+func (tp *fakeTokenProvider) ListTokens(_ context.Context, name string) ([]Token, error) {
+	fakeTokens.Lock()
+	defer fakeTokens.Unlock()
+
+	return append([]Token{}, fakeTokens.accounts[name]...), nil
+}
+
+func (tp *fakeTokenProvider) DeleteToken(_ context.Context, name, id string) error {
+	fakeTokens.Lock()
+	defer fakeTokens.Unlock()
+
+	list := fakeTokens.accounts[name]
+	for i, el := range list {
+		if el.ID == id {
+			fakeTokens.accounts[name] = append(list[:i:i], list[i+1:]...)
+			return nil
+		}
+	}
+	return &notFoundError{msg: fmt.Sprintf("account '%s' has no token with id '%s'", name, id)}
+}
+
+func (tp *fakeTokenProvider) Version(context.Context) (string, error) {
+	return "fake", nil
+}
@@ -0,0 +1,310 @@
+package accounts
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/textproto"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// gRPC-web wire format, see:
+// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md
+const (
+	grpcWebContentType = "application/grpc-web+proto"
+
+	grpcWebFrameData    byte = 0x00
+	grpcWebFrameTrailer byte = 0x80
+
+	grpcStatusOK       = "0"
+	grpcStatusNotFound = "5"
+)
+
+// ArgoCD gRPC methods, see:
+// https://github.com/argoproj/argo-cd/tree/master/server
+const (
+	methodSessionCreate      = "session.SessionService/Create"
+	methodAccountGet         = "account.AccountService/GetAccount"
+	methodAccountCreateToken = "account.AccountService/CreateToken"
+	methodAccountDeleteToken = "account.AccountService/DeleteToken"
+	methodVersion            = "version.VersionService/Version"
+)
+
+// grpcWebTokenProvider talks to the ArgoCD server over gRPC-web, as the
+// argocd CLI does with --grpc-web, for servers reachable only through
+// HTTP/1.1 ingresses.
+type grpcWebTokenProvider struct {
+	*tokenProvider
+}
+
+func (tp *grpcWebTokenProvider) CreateSession(ctx context.Context, user, pass string) (string, error) {
+	// session.SessionCreateRequest{username = 1, password = 2}
+	var req []byte
+	req = appendString(req, 1, user)
+	req = appendString(req, 2, pass)
+
+	res, err := tp.invoke(ctx, methodSessionCreate, req, false)
+	if err != nil {
+		return "", err
+	}
+
+	// session.SessionResponse{token = 1}
+	return stringField(res, 1)
+}
+
+func (tp *grpcWebTokenProvider) CreateTokenForAccount(ctx context.Context, name, id string, expiresIn int64) (string, error) {
+	// account.CreateTokenRequest{name = 1, expiresIn = 2, id = 3}
+	var req []byte
+	req = appendString(req, 1, name)
+	if expiresIn != 0 {
+		req = protowire.AppendTag(req, 2, protowire.VarintType)
+		req = protowire.AppendVarint(req, uint64(expiresIn))
+	}
+	req = appendString(req, 3, id)
+
+	res, err := tp.invoke(ctx, methodAccountCreateToken, req, true)
+	if err != nil {
+		return "", err
+	}
+
+	// account.CreateTokenResponse{token = 1}
+	return stringField(res, 1)
+}
+
+func (tp *grpcWebTokenProvider) ListTokens(ctx context.Context, name string) ([]Token, error) {
+	// account.GetAccountRequest{name = 1}
+	var req []byte
+	req = appendString(req, 1, name)
+
+	res, err := tp.invoke(ctx, methodAccountGet, req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// account.Account{tokens = 4}
+	tokens := []Token{}
+	err = walkFields(res, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+		if num != 4 || typ != protowire.BytesType {
+			return nil
+		}
+
+		// account.Token{id = 1, issuedAt = 2, expiresAt = 3}
+		var tok Token
+		err := walkFields(b, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+			switch {
+			case num == 1 && typ == protowire.BytesType:
+				tok.ID = string(b)
+			case num == 2 && typ == protowire.VarintType:
+				tok.IssuedAt = int64(v)
+			case num == 3 && typ == protowire.VarintType:
+				tok.ExpiresAt = int64(v)
+			}
+			return nil
+		})
+		tokens = append(tokens, tok)
+		return err
+	})
+
+	return tokens, err
+}
+
+func (tp *grpcWebTokenProvider) DeleteToken(ctx context.Context, name, id string) error {
+	// account.DeleteTokenRequest{name = 1, id = 2}
+	var req []byte
+	req = appendString(req, 1, name)
+	req = appendString(req, 2, id)
+
+	_, err := tp.invoke(ctx, methodAccountDeleteToken, req, true)
+	return err
+}
+
+func (tp *grpcWebTokenProvider) Version(ctx context.Context) (string, error) {
+	res, err := tp.invoke(ctx, methodVersion, nil, false)
+	if err != nil {
+		return "", err
+	}
+
+	// version.VersionMessage{Version = 1}
+	ver, err := stringField(res, 1)
+	if err == nil && len(ver) == 0 {
+		err = errors.New("argocd version not found in response")
+	}
+	return ver, err
+}
+
+// invoke calls the supplied unary gRPC method and returns the response message.
+func (tp *grpcWebTokenProvider) invoke(ctx context.Context, method string, msg []byte, auth bool) ([]byte, error) {
+	url := strings.TrimRight(tp.serverAddr, "/") + "/" + method
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(grpcWebFrame(msg)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", grpcWebContentType)
+	req.Header.Set("Accept", grpcWebContentType)
+	req.Header.Set("X-Grpc-Web", "1")
+	req.Header.Set("User-Agent", tp.userAgent)
+	if auth {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tp.authToken))
+	}
+
+	if tp.debugClient {
+		debug(httputil.DumpRequestOut(req, true))
+	}
+
+	res, err := tp.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if tp.debugClient {
+		debug(httputil.DumpResponse(res, true))
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("argocd %s request failed: %s", method, res.Status)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := parseGRPCWebResponse(body, res.Header)
+	if err != nil {
+		return nil, fmt.Errorf("argocd %s request failed: %w", method, err)
+	}
+
+	return out, nil
+}
+
+// grpcWebFrame wraps the supplied message in a gRPC-web data frame.
+func grpcWebFrame(msg []byte) []byte {
+	res := make([]byte, 5, 5+len(msg))
+	res[0] = grpcWebFrameData
+	binary.BigEndian.PutUint32(res[1:], uint32(len(msg)))
+	return append(res, msg...)
+}
+
+// parseGRPCWebResponse returns the message of a gRPC-web response, or an
+// error if the call status is not OK; the status is looked up in the
+// trailer frame, or in the headers for trailers-only responses.
+func parseGRPCWebResponse(body []byte, hdr http.Header) ([]byte, error) {
+	var msg []byte
+	trailers := http.Header{}
+
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, errors.New("truncated grpc-web frame")
+		}
+
+		flag, size := body[0], binary.BigEndian.Uint32(body[1:5])
+		if uint32(len(body)-5) < size {
+			return nil, errors.New("truncated grpc-web frame")
+		}
+		payload := body[5 : 5+size]
+		body = body[5+size:]
+
+		if flag&grpcWebFrameTrailer != 0 {
+			tr, err := parseGRPCWebTrailers(payload)
+			if err != nil {
+				return nil, err
+			}
+			trailers = tr
+			continue
+		}
+		msg = payload
+	}
+
+	status, message := trailers.Get("Grpc-Status"), trailers.Get("Grpc-Message")
+	if len(status) == 0 {
+		status, message = hdr.Get("Grpc-Status"), hdr.Get("Grpc-Message")
+	}
+
+	if len(status) == 0 {
+		return nil, errors.New("missing grpc-status")
+	}
+
+	switch status {
+	case grpcStatusOK:
+	case grpcStatusNotFound:
+		return nil, &notFoundError{msg: fmt.Sprintf("grpc-status %s: %s", status, message)}
+	default:
+		return nil, fmt.Errorf("grpc-status %s: %s", status, message)
+	}
+
+	return msg, nil
+}
+
+func parseGRPCWebTrailers(b []byte) (http.Header, error) {
+	b = append(bytes.TrimRight(b, "\r\n"), "\r\n\r\n"...)
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(b)))
+	hdr, err := r.ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("invalid grpc-web trailers: %w", err)
+	}
+	return http.Header(hdr), nil
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// walkFields calls fn for each field of the supplied protobuf message;
+// b holds the value of length-delimited fields, v the one of varints.
+func walkFields(msg []byte, fn func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+
+		var (
+			b []byte
+			v uint64
+		)
+		switch typ {
+		case protowire.BytesType:
+			b, n = protowire.ConsumeBytes(msg)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(msg)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+
+		if err := fn(num, typ, b, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringField returns the last value of the supplied string field.
+func stringField(msg []byte, want protowire.Number) (string, error) {
+	var res string
+	err := walkFields(msg, func(num protowire.Number, typ protowire.Type, b []byte, _ uint64) error {
+		if num == want && typ == protowire.BytesType {
+			res = string(b)
+		}
+		return nil
+	})
+	return res, err
+}
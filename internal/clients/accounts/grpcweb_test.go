@@ -0,0 +1,67 @@
+package accounts
+
+import (
+	"encoding/binary"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseGRPCWebResponse(t *testing.T) {
+	trailer := func(s string) []byte {
+		frame := make([]byte, 5, 5+len(s))
+		frame[0] = grpcWebFrameTrailer
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(s)))
+		return append(frame, s...)
+	}
+
+	cases := map[string]struct {
+		body    []byte
+		hdr     http.Header
+		want    []byte
+		wantErr string
+	}{
+		"OK": {
+			body: append(grpcWebFrame([]byte("msg")), trailer("grpc-status: 0\r\n")...),
+			want: []byte("msg"),
+		},
+		"NoTrailingCRLF": {
+			body: append(grpcWebFrame([]byte("msg")), trailer("grpc-status:0")...),
+			want: []byte("msg"),
+		},
+		"TrailersOnly": {
+			hdr:     http.Header{"Grpc-Status": {"7"}, "Grpc-Message": {"permission denied"}},
+			wantErr: "grpc-status 7: permission denied",
+		},
+		"MissingStatus": {
+			body:    grpcWebFrame([]byte("msg")),
+			wantErr: "missing grpc-status",
+		},
+		"Truncated": {
+			body:    grpcWebFrame([]byte("msg"))[:6],
+			wantErr: "truncated grpc-web frame",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if tc.hdr == nil {
+				tc.hdr = http.Header{}
+			}
+			got, err := parseGRPCWebResponse(tc.body, tc.hdr)
+			if len(tc.wantErr) > 0 {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("parseGRPCWebResponse(...): want error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGRPCWebResponse(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parseGRPCWebResponse(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package accounts
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// ArgoCD settings stored in argocd-secret, see:
+// https://argo-cd.readthedocs.io/en/stable/operator-manual/argocd-secret-yaml/
+const (
+	serverSecretKey     = "server.secretkey"
+	accountTokensKeyFmt = "accounts.%s.tokens"
+
+	tokenIssuer      = "argocd"
+	apiKeySubjectFmt = "%s:" + capabilityAPIKey
+)
+
+func init() {
+	Register(BackendLocalJWT, Capabilities{Expiry: true, Revoke: true, List: true}, newLocalJWTTokenProvider)
+}
+
+// newLocalJWTTokenProvider creates a token provider signing tokens locally.
+func newLocalJWTTokenProvider(opts *TokenProviderOptions) (TokenProvider, error) {
+	if opts.SecretStore == nil {
+		return nil, errors.New("unspecified argocd-secret store for the LocalJWT token provider")
+	}
+	return &localJWTTokenProvider{store: opts.SecretStore, now: time.Now}, nil
+}
+
+// SecretStore gives access to the argocd-secret data.
+type SecretStore interface {
+	// Get returns the secret data.
+	Get(ctx context.Context) (map[string][]byte, error)
+	// Update applies fn to the secret data and stores the result.
+	Update(ctx context.Context, fn func(data map[string][]byte) error) error
+}
+
+// localJWTTokenProvider issues tokens without the ArgoCD API server, by
+// signing them with server.secretkey as ArgoCD does and registering them in
+// the account tokens list, so that ArgoCD accepts them.
+type localJWTTokenProvider struct {
+	store SecretStore
+	now   func() time.Time
+}
+
+// accountToken is an entry of the accounts.<name>.tokens list.
+type accountToken struct {
+	ID        string `json:"id"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// jwtClaims are the claims of the tokens issued by ArgoCD.
+type jwtClaims struct {
+	Issuer    string `json:"iss,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ID        string `json:"jti,omitempty"`
+}
+
+func (tp *localJWTTokenProvider) SetAuthToken(string) {}
+
+func (tp *localJWTTokenProvider) CreateSession(context.Context, string, string) (string, error) {
+	return "", errors.New("sessions are not supported by the LocalJWT token provider")
+}
+
+func (tp *localJWTTokenProvider) CreateTokenForAccount(ctx context.Context, name, id string, expiresIn int64) (string, error) {
+	if len(id) == 0 {
+		id = string(uuid.NewUUID())
+	}
+
+	now := tp.now().UTC()
+	claims := jwtClaims{
+		Issuer:    tokenIssuer,
+		Subject:   fmt.Sprintf(apiKeySubjectFmt, name),
+		NotBefore: now.Unix(),
+		IssuedAt:  now.Unix(),
+		ID:        id,
+	}
+	if expiresIn > 0 {
+		claims.ExpiresAt = now.Add(time.Duration(expiresIn) * time.Second).Unix()
+	}
+
+	var token string
+	err := tp.store.Update(ctx, func(data map[string][]byte) error {
+		key := data[serverSecretKey]
+		if len(key) == 0 {
+			return fmt.Errorf("%s not found in argocd-secret", serverSecretKey)
+		}
+
+		tokens, err := accountTokens(data, name)
+		if err != nil {
+			return err
+		}
+		for _, el := range tokens {
+			if el.ID == id {
+				return fmt.Errorf("account '%s' already has a token with id '%s'", name, id)
+			}
+		}
+
+		token, err = signHS256(claims, key)
+		if err != nil {
+			return err
+		}
+
+		tokens = append(tokens, accountToken{ID: id, IssuedAt: claims.IssuedAt, ExpiresAt: claims.ExpiresAt})
+		return setAccountTokens(data, name, tokens)
+	})
+
+	return token, err
+}
+
+func (tp *localJWTTokenProvider) ListTokens(ctx context.Context, name string) ([]Token, error) {
+	data, err := tp.store.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := accountTokens(data, name)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Token, 0, len(tokens))
+	for _, el := range tokens {
+		res = append(res, Token{ID: el.ID, IssuedAt: el.IssuedAt, ExpiresAt: el.ExpiresAt})
+	}
+	return res, nil
+}
+
+func (tp *localJWTTokenProvider) DeleteToken(ctx context.Context, name, id string) error {
+	return tp.store.Update(ctx, func(data map[string][]byte) error {
+		tokens, err := accountTokens(data, name)
+		if err != nil {
+			return err
+		}
+
+		for i, el := range tokens {
+			if el.ID == id {
+				return setAccountTokens(data, name, append(tokens[:i], tokens[i+1:]...))
+			}
+		}
+		return &notFoundError{msg: fmt.Sprintf("account '%s' has no token with id '%s'", name, id)}
+	})
+}
+
+// Version checks that tokens can be signed; the ArgoCD version is unknown.
+func (tp *localJWTTokenProvider) Version(ctx context.Context) (string, error) {
+	data, err := tp.store.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if len(data[serverSecretKey]) == 0 {
+		return "", fmt.Errorf("%s not found in argocd-secret", serverSecretKey)
+	}
+	return "", nil
+}
+
+func accountTokens(data map[string][]byte, name string) ([]accountToken, error) {
+	res := []accountToken{}
+
+	bin := data[fmt.Sprintf(accountTokensKeyFmt, name)]
+	if len(bin) == 0 {
+		return res, nil
+	}
+
+	if err := json.Unmarshal(bin, &res); err != nil {
+		return nil, fmt.Errorf("invalid tokens of account '%s': %w", name, err)
+	}
+	return res, nil
+}
+
+func setAccountTokens(data map[string][]byte, name string, tokens []accountToken) error {
+	bin, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	data[fmt.Sprintf(accountTokensKeyFmt, name)] = bin
+	return nil
+}
+
+// signHS256 returns the supplied claims as a JWT signed with HMAC SHA-256.
+func signHS256(claims jwtClaims, key []byte) (string, error) {
+	enc := base64.RawURLEncoding
+
+	hdr, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(&claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := enc.EncodeToString(hdr) + "." + enc.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(unsigned))
+
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil)), nil
+}
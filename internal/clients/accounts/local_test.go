@@ -0,0 +1,103 @@
+package accounts
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// memorySecretStore is an in-memory argocd-secret.
+type memorySecretStore map[string][]byte
+
+func (s memorySecretStore) Get(context.Context) (map[string][]byte, error) {
+	return s, nil
+}
+
+func (s memorySecretStore) Update(_ context.Context, fn func(data map[string][]byte) error) error {
+	return fn(s)
+}
+
+func TestLocalJWTTokenProvider(t *testing.T) {
+	key := []byte("server-secret-key")
+	store := memorySecretStore{serverSecretKey: key}
+
+	cli, err := NewTokenProvider(&TokenProviderOptions{Backend: BackendLocalJWT, SecretStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.(*localJWTTokenProvider).now = func() time.Time { return time.Unix(1650000000, 0) }
+
+	token, err := cli.CreateTokenForAccount(context.Background(), "krateo-dashboard", "my-token", 3600)
+	if err != nil {
+		t.Fatalf("CreateTokenForAccount(...): %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("CreateTokenForAccount(...): malformed token %q", token)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if want := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)); parts[2] != want {
+		t.Errorf("CreateTokenForAccount(...): invalid HS256 signature")
+	}
+
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	wantClaims := map[string]interface{}{
+		"iss": "argocd",
+		"sub": "krateo-dashboard:apiKey",
+		"jti": "my-token",
+		"iat": float64(1650000000),
+		"nbf": float64(1650000000),
+		"exp": float64(1650003600),
+	}
+	if diff := cmp.Diff(wantClaims, claims); diff != "" {
+		t.Errorf("CreateTokenForAccount(...): -want, +got claims:\n%s", diff)
+	}
+
+	if got, want := string(store["accounts.krateo-dashboard.tokens"]), `[{"id":"my-token","iat":1650000000,"exp":1650003600}]`; got != want {
+		t.Errorf("CreateTokenForAccount(...): want registered tokens %s, got %s", want, got)
+	}
+
+	if _, err := cli.CreateTokenForAccount(context.Background(), "krateo-dashboard", "my-token", 0); err == nil {
+		t.Errorf("CreateTokenForAccount(...) with a duplicated id: want error, got nil")
+	}
+
+	if err := cli.DeleteToken(context.Background(), "krateo-dashboard", "my-token"); err != nil {
+		t.Fatalf("DeleteToken(...): %v", err)
+	}
+
+	got, err := cli.ListTokens(context.Background(), "krateo-dashboard")
+	if err != nil {
+		t.Fatalf("ListTokens(...): %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("ListTokens(...) after delete: want no tokens, got %v", got)
+	}
+}
+
+func TestLocalJWTTokenProviderNoSecretKey(t *testing.T) {
+	cli, err := NewTokenProvider(&TokenProviderOptions{Backend: BackendLocalJWT, SecretStore: memorySecretStore{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cli.CreateTokenForAccount(context.Background(), "krateo-dashboard", "", 0); err == nil {
+		t.Errorf("CreateTokenForAccount(...) without server.secretkey: want error, got nil")
+	}
+	if _, err := cli.Version(context.Background()); err == nil {
+		t.Errorf("Version(...) without server.secretkey: want error, got nil")
+	}
+}
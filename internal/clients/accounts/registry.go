@@ -0,0 +1,102 @@
+package accounts
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Backend identifies how tokens are issued.
+type Backend string
+
+// Token provider backends.
+const (
+	// BackendAPI issues tokens calling the ArgoCD API.
+	BackendAPI Backend = "API"
+	// BackendLocalJWT signs tokens locally with the ArgoCD server secret key.
+	BackendLocalJWT Backend = "LocalJWT"
+	// BackendFake issues tokens from memory, without any ArgoCD.
+	BackendFake Backend = "Fake"
+)
+
+// Capabilities declares what a token provider backend supports.
+type Capabilities struct {
+	// Expiry tokens can be issued with an expiration.
+	Expiry bool
+	// Revoke tokens can be deleted.
+	Revoke bool
+	// List the tokens of an account can be listed.
+	List bool
+	// Session an admin session is required to issue tokens.
+	Session bool
+}
+
+// A BackendFactory creates a TokenProvider from a set of config options.
+type BackendFactory func(opts *TokenProviderOptions) (TokenProvider, error)
+
+type backend struct {
+	caps    Capabilities
+	factory BackendFactory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[Backend]backend{}
+)
+
+// Register makes a token provider backend available by the supplied name;
+// it panics if the name is already registered.
+func Register(name Backend, caps Capabilities, factory BackendFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("token provider backend %s already registered", name))
+	}
+	registry[name] = backend{caps: caps, factory: factory}
+}
+
+// Backends returns the names of the registered backends, sorted.
+func Backends() []Backend {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	res := make([]Backend, 0, len(registry))
+	for name := range registry {
+		res = append(res, name)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+// CapabilitiesOf returns the capabilities of the named backend; the API
+// backend is used if name is empty.
+func CapabilitiesOf(name Backend) (Capabilities, error) {
+	b, err := lookup(name)
+	return b.caps, err
+}
+
+// NewTokenProvider creates a new ArgoCD token provider from a set of config
+// options, using the backend they name.
+func NewTokenProvider(opts *TokenProviderOptions) (TokenProvider, error) {
+	b, err := lookup(opts.Backend)
+	if err != nil {
+		return nil, err
+	}
+	return b.factory(opts)
+}
+
+func lookup(name Backend) (backend, error) {
+	if len(name) == 0 {
+		name = BackendAPI
+	}
+
+	registryMu.RLock()
+	b, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return backend{}, fmt.Errorf("unknown token provider backend %q (available: %v)", name, Backends())
+	}
+	return b, nil
+}
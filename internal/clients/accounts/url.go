@@ -0,0 +1,51 @@
+package accounts
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// NormalizeServerURL validates the supplied ArgoCD server url and returns
+// it joined with the supplied root path (see argocd-server --rootpath),
+// without trailing slashes.
+func NormalizeServerURL(server, rootPath string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(server))
+	if err != nil {
+		return "", fmt.Errorf("invalid argocd server url %q: %w", server, err)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", fmt.Errorf("invalid argocd server url %q: scheme must be https or http", server)
+	}
+
+	if len(u.Hostname()) == 0 {
+		return "", fmt.Errorf("invalid argocd server url %q: missing host", server)
+	}
+
+	if len(u.RawQuery) > 0 || len(u.Fragment) > 0 || u.User != nil {
+		return "", fmt.Errorf("invalid argocd server url %q: unexpected userinfo, query or fragment", server)
+	}
+
+	p := path.Clean("/" + path.Join(u.Path, rootPath))
+	if p == "/" {
+		p = ""
+	}
+
+	u.Path = p
+	u.RawPath = ""
+
+	return u.String(), nil
+}
+
+// joinURL appends the supplied path elements, escaping them, to base.
+func joinURL(base string, elems ...string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimRight(base, "/"))
+	for _, el := range elems {
+		sb.WriteString("/")
+		sb.WriteString(url.PathEscape(el))
+	}
+	return sb.String()
+}
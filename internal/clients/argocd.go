@@ -8,9 +8,12 @@ import (
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 
 	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
@@ -22,32 +25,40 @@ const (
 )
 
 // GetConfig constructs a ClientOptions configuration that can be used to authenticate to argocd
-// API by the argocd Go client
+// API by the argocd Go client; the default ProviderConfig is used if no
+// providerConfigRef is given.
 func GetConfig(ctx context.Context, c client.Client, mg resource.Managed) (*accounts.TokenProviderOptions, error) {
-	switch {
-	case mg.GetProviderConfigReference() != nil:
-		return UseProviderConfig(ctx, c, mg)
-	default:
-		return nil, errors.New("providerConfigRef is not given")
-	}
+	return UseProviderConfig(ctx, c, mg)
 }
 
 // UseProviderConfig to produce a config that can be used to create an ArgoCD client.
 func UseProviderConfig(ctx context.Context, k client.Client, mg resource.Managed) (*accounts.TokenProviderOptions, error) {
-	pc := &v1alpha1.ProviderConfig{}
-	if err := k.Get(ctx, types.NamespacedName{Name: mg.GetProviderConfigReference().Name}, pc); err != nil {
-		return nil, errors.Wrap(err, "cannot get referenced Provider")
+	pc, _, err := GetProviderConfig(ctx, k, mg)
+	if err != nil {
+		return nil, err
 	}
 
-	t := resource.NewProviderConfigUsageTracker(k, &v1alpha1.ProviderConfigUsage{})
-	if err := t.Track(ctx, mg); err != nil {
+	if err := trackProviderConfigUsage(ctx, k, mg, pc); err != nil {
 		return nil, errors.Wrap(err, "cannot track ProviderConfig usage")
 	}
 
-	opts := &accounts.TokenProviderOptions{
-		ServerUrl:   pc.Spec.ServerUrl,
-		UserAgent:   pc.Spec.UserAgent,
-		DebugClient: isBoolPtrEqualToBool(pc.Spec.DebugClient, true),
+	opts, err := NewTokenProviderOptions(ctx, k, pc)
+	if err != nil {
+		return nil, err
+	}
+
+	return authenticate(ctx, k, pc, opts)
+}
+
+// authenticate logs in to the ArgoCD server, if the token provider needs
+// a session.
+func authenticate(ctx context.Context, k client.Client, pc *v1alpha1.ProviderConfig, opts *accounts.TokenProviderOptions) (*accounts.TokenProviderOptions, error) {
+	caps, err := accounts.CapabilitiesOf(opts.Backend)
+	if err != nil {
+		return nil, err
+	}
+	if !caps.Session {
+		return opts, nil
 	}
 
 	pass, err := GetInitialAdminPassword(ctx, k, pc)
@@ -55,7 +66,7 @@ func UseProviderConfig(ctx context.Context, k client.Client, mg resource.Managed
 		return nil, err
 	}
 
-	token, err := accounts.Login(opts, "admin", pass)
+	token, err := accounts.Login(ctx, opts, "admin", pass)
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +76,123 @@ func UseProviderConfig(ctx context.Context, k client.Client, mg resource.Managed
 	return opts, nil
 }
 
+// GetProviderConfig returns the ProviderConfig of the supplied managed
+// resource. The providerConfigRef of a namespaced resource names a
+// ProviderConfigBinding in its namespace, which is returned too. Without
+// a providerConfigRef the default one is returned.
+func GetProviderConfig(ctx context.Context, k client.Client, mg resource.Managed) (*v1alpha1.ProviderConfig, *v1alpha1.ProviderConfigBinding, error) {
+	name := ProviderConfigName(mg)
+
+	var pcb *v1alpha1.ProviderConfigBinding
+	if ns := mg.GetNamespace(); len(ns) > 0 {
+		pcb = &v1alpha1.ProviderConfigBinding{}
+		if err := k.Get(ctx, types.NamespacedName{Namespace: ns, Name: name}, pcb); err != nil {
+			return nil, nil, errors.Wrap(err, "cannot get referenced ProviderConfigBinding")
+		}
+		name = pcb.Spec.ProviderConfigRef.Name
+	}
+
+	pc := &v1alpha1.ProviderConfig{}
+	if err := k.Get(ctx, types.NamespacedName{Name: name}, pc); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot get referenced Provider")
+	}
+
+	return pc, pcb, nil
+}
+
+// trackProviderConfigUsage records that the supplied managed resource uses
+// the supplied ProviderConfig.
+func trackProviderConfigUsage(ctx context.Context, k client.Client, mg resource.Managed, pc *v1alpha1.ProviderConfig) error {
+	if len(mg.GetNamespace()) == 0 {
+		// the usage records the ProviderConfig actually used: the
+		// resource may reference nothing at all.
+		u := mg.DeepCopyObject().(resource.Managed)
+		u.SetProviderConfigReference(&xpv1.Reference{Name: pc.Name})
+
+		return resource.NewProviderConfigUsageTracker(k, &v1alpha1.ProviderConfigUsage{}).Track(ctx, u)
+	}
+
+	// ReleaseProviderConfigUsage deletes the usage of a namespaced
+	// resource along with its finalizer.
+	if meta.WasDeleted(mg) {
+		return nil
+	}
+
+	return trackNamespacedUsage(ctx, k, mg, pc)
+}
+
+// trackNamespacedUsage creates or updates the ProviderConfigUsage of the
+// supplied namespaced managed resource, as ProviderConfigUsageTracker does;
+// the resource references a ProviderConfigBinding, the usage the
+// ProviderConfig actually used.
+// The garbage collector deletes the cluster scoped objects owned by a
+// namespaced one, and the ProviderConfig reconciler the usages without a
+// controller: the usage is controlled by the ProviderConfig instead.
+func trackNamespacedUsage(ctx context.Context, k client.Client, mg resource.Managed, pc *v1alpha1.ProviderConfig) error {
+	gvk := mg.GetObjectKind().GroupVersionKind()
+
+	pcu := &v1alpha1.ProviderConfigUsage{}
+	pcu.SetName(string(mg.GetUID()))
+	pcu.SetLabels(map[string]string{xpv1.LabelKeyProviderName: pc.Name})
+	pcu.SetOwnerReferences([]metav1.OwnerReference{meta.AsController(meta.TypedReferenceTo(pc, v1alpha1.ProviderConfigGroupVersionKind))})
+	pcu.SetProviderConfigReference(xpv1.Reference{Name: pc.Name})
+	pcu.SetResourceReference(xpv1.TypedReference{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Name:       mg.GetName(),
+	})
+
+	err := resource.NewAPIUpdatingApplicator(k).Apply(ctx, pcu,
+		resource.AllowUpdateIf(func(current, _ runtime.Object) bool {
+			cur := current.(*v1alpha1.ProviderConfigUsage)
+			return cur.GetProviderConfigReference() != pcu.GetProviderConfigReference() || !metav1.IsControlledBy(cur, pc)
+		}),
+	)
+	return errors.Wrap(resource.Ignore(resource.IsNotAllowed, err), "cannot apply ProviderConfigUsage")
+}
+
+// ReleaseProviderConfigUsage deletes the ProviderConfigUsage of the supplied
+// namespaced managed resource.
+func ReleaseProviderConfigUsage(ctx context.Context, k client.Client, mg resource.Managed) error {
+	pcu := &v1alpha1.ProviderConfigUsage{}
+	pcu.SetName(string(mg.GetUID()))
+
+	err := k.Delete(ctx, pcu)
+	return errors.Wrap(resource.IgnoreNotFound(err), "cannot delete ProviderConfigUsage")
+}
+
+// NewTokenProviderOptions returns the options to connect to the ArgoCD
+// server of the supplied ProviderConfig, without authentication.
+func NewTokenProviderOptions(ctx context.Context, k client.Client, pc *v1alpha1.ProviderConfig) (*accounts.TokenProviderOptions, error) {
+	serverURL, err := ResolveServerURL(ctx, k, pc)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &accounts.TokenProviderOptions{
+		ServerUrl:   serverURL,
+		UserAgent:   pc.Spec.UserAgent,
+		DebugClient: isBoolPtrEqualToBool(pc.Spec.DebugClient, true),
+		Insecure:    pc.Spec.Insecure == nil || *pc.Spec.Insecure,
+		GRPCWeb:     isBoolPtrEqualToBool(pc.Spec.GRPCWeb, true),
+		Backend:     accounts.Backend(pc.Spec.TokenProvider),
+	}
+
+	if ref := pc.Spec.ArgoCDSecretRef; ref != nil {
+		opts.SecretStore = NewArgoCDSecretStore(k, ref)
+	}
+
+	if ref := pc.Spec.CertificateAuthorityRef; ref != nil {
+		ca, err := getSecret(ctx, k, ref)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot get CA certificate")
+		}
+		opts.CACert = []byte(ca)
+	}
+
+	return opts, nil
+}
+
 // GetInitialAdminPassword returns the ArgoCD initial admin password.
 func GetInitialAdminPassword(ctx context.Context, k client.Client, pc *v1alpha1.ProviderConfig) (string, error) {
 	ref := &xpv1.SecretKeySelector{
@@ -0,0 +1,284 @@
+package clients
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis"
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/argocdtest"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/accounts"
+)
+
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+
+	s := runtime.NewScheme()
+	if err := corev1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+
+	return fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build()
+}
+
+func newAdminSecret(pass string) *corev1.Secret {
+	s := &corev1.Secret{}
+	s.Name = argocdInititalAdminSecret
+	s.Namespace = "argocd"
+	s.Data = map[string][]byte{corev1.BasicAuthPasswordKey: []byte(pass)}
+	return s
+}
+
+func newEndpoint() *endpointsv1alpha1.Endpoint {
+	cr := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "ci", UID: "1234"}}
+	cr.Spec.ProviderConfigReference = &xpv1.Reference{Name: "default"}
+	return cr
+}
+
+func TestUseProviderConfig(t *testing.T) {
+	cases := map[string]struct {
+		backend   string
+		pass      string
+		failure   *argocdtest.Failure
+		noPC      bool
+		serverRef bool
+		wantErr   string
+	}{
+		"Login": {
+			pass: argocdtest.AdminPassword,
+		},
+		"WrongPassword": {
+			pass:    "wrong",
+			wantErr: "401 Unauthorized",
+		},
+		"NoProviderConfig": {
+			noPC:    true,
+			wantErr: "cannot get referenced Provider",
+		},
+		"ServerURLAndServerRef": {
+			pass:      argocdtest.AdminPassword,
+			serverRef: true,
+			wantErr:   errServerURLAndRef,
+		},
+		"NoSessionBackend": {
+			backend: string(accounts.BackendFake),
+		},
+		"Unauthorized": {
+			pass:    argocdtest.AdminPassword,
+			failure: failure(argocdtest.Unauthorized()),
+			wantErr: "401 Unauthorized",
+		},
+		"NotFound": {
+			pass:    argocdtest.AdminPassword,
+			failure: failure(argocdtest.NotFound()),
+			wantErr: "404 Not Found",
+		},
+		"TooManyRequests": {
+			pass:    argocdtest.AdminPassword,
+			failure: failure(argocdtest.TooManyRequests()),
+			wantErr: "429 Too Many Requests",
+		},
+		"MalformedJSON": {
+			pass:    argocdtest.AdminPassword,
+			failure: failure(argocdtest.MalformedJSON()),
+			wantErr: "unexpected end of JSON input",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := argocdtest.NewServer()
+			defer srv.Close()
+
+			if tc.failure != nil {
+				srv.Fail(argocdtest.RouteSession, *tc.failure)
+			}
+
+			objs := []client.Object{newAdminSecret(tc.pass)}
+			if !tc.noPC {
+				pc := &v1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+				pc.Spec.ServerUrl = srv.URL
+				pc.Spec.TokenProvider = tc.backend
+				if tc.serverRef {
+					pc.Spec.ServerRef = &v1alpha1.ServiceReference{Namespace: "argocd"}
+				}
+				pc.Spec.Credentials = &v1alpha1.ProviderCredentials{
+					Source: xpv1.CredentialsSourceSecret,
+					CommonCredentialSelectors: xpv1.CommonCredentialSelectors{
+						SecretRef: &xpv1.SecretKeySelector{
+							SecretReference: xpv1.SecretReference{Name: argocdInititalAdminSecret, Namespace: "argocd"},
+							Key:             corev1.BasicAuthPasswordKey,
+						},
+					},
+				}
+				objs = append(objs, pc)
+			}
+
+			kube := newFakeClient(t, objs...)
+
+			opts, err := UseProviderConfig(context.Background(), kube, newEndpoint())
+			if len(tc.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("UseProviderConfig(...): want error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UseProviderConfig(...): %v", err)
+			}
+
+			if got, want := opts.ServerUrl, srv.URL; got != want {
+				t.Errorf("UseProviderConfig(...): want server url %q, got %q", want, got)
+			}
+
+			caps, _ := accounts.CapabilitiesOf(opts.Backend)
+			if caps.Session && len(opts.AuthToken) == 0 {
+				t.Errorf("UseProviderConfig(...): want auth token, got none")
+			}
+			if !caps.Session && srv.Calls(argocdtest.RouteSession) > 0 {
+				t.Errorf("UseProviderConfig(...): want no session, got %d logins", srv.Calls(argocdtest.RouteSession))
+			}
+		})
+	}
+}
+
+func TestUseProviderConfigTimeout(t *testing.T) {
+	srv := argocdtest.NewServer()
+	defer srv.Close()
+
+	srv.Fail(argocdtest.RouteSession, argocdtest.Slow(time.Second))
+
+	pc := &v1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	pc.Spec.ServerUrl = srv.URL
+
+	kube := newFakeClient(t, pc, newAdminSecret(argocdtest.AdminPassword))
+
+	opts, err := NewTokenProviderOptions(context.Background(), kube, pc)
+	if err != nil {
+		t.Fatalf("NewTokenProviderOptions(...): %v", err)
+	}
+	opts.Timeout = 50 * time.Millisecond
+
+	if _, err := accounts.Login(context.Background(), opts, "admin", argocdtest.AdminPassword); err == nil || !strings.Contains(err.Error(), "Client.Timeout exceeded") {
+		t.Errorf("Login(...): want timeout error, got %v", err)
+	}
+}
+
+func failure(f argocdtest.Failure) *argocdtest.Failure {
+	return &f
+}
+
+func TestTrackProviderConfigUsage(t *testing.T) {
+	pc := &v1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "argocd", UID: "9abc"}}
+
+	namespaced := func() *endpointsv1alpha1.NamespacedEndpoint {
+		cr := &endpointsv1alpha1.NamespacedEndpoint{ObjectMeta: metav1.ObjectMeta{Name: "ci", Namespace: "team-a", UID: "5678"}}
+		cr.SetGroupVersionKind(endpointsv1alpha1.NamespacedEndpointGroupVersionKind)
+		cr.Spec.ProviderConfigReference = &xpv1.Reference{Name: "default"}
+		return cr
+	}
+
+	cases := map[string]struct {
+		mg        func() resource.Managed
+		existing  *v1alpha1.ProviderConfigUsage
+		wantUsage bool
+		wantOwner types.UID
+	}{
+		"Endpoint": {
+			mg: func() resource.Managed {
+				cr := newEndpoint()
+				cr.SetGroupVersionKind(endpointsv1alpha1.EndpointGroupVersionKind)
+				return cr
+			},
+			wantUsage: true,
+			wantOwner: "1234",
+		},
+		"NamespacedEndpoint": {
+			mg:        func() resource.Managed { return namespaced() },
+			wantUsage: true,
+			wantOwner: "9abc",
+		},
+		"NamespacedEndpointOwnedUsage": {
+			mg: func() resource.Managed { return namespaced() },
+			existing: &v1alpha1.ProviderConfigUsage{ObjectMeta: metav1.ObjectMeta{
+				Name:            "5678",
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "argocd.krateo.io/v1alpha1", Kind: "NamespacedEndpoint", Name: "ci", UID: "5678"}},
+			}},
+			wantUsage: true,
+			wantOwner: "9abc",
+		},
+		"NamespacedEndpointDeleted": {
+			mg: func() resource.Managed {
+				cr := namespaced()
+				now := metav1.Now()
+				cr.SetDeletionTimestamp(&now)
+				return cr
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			objs := []client.Object{pc}
+			if tc.existing != nil {
+				objs = append(objs, tc.existing)
+			}
+			kube := newFakeClient(t, objs...)
+
+			mg := tc.mg()
+			if err := trackProviderConfigUsage(ctx, kube, mg, pc); err != nil {
+				t.Fatalf("trackProviderConfigUsage(...): %v", err)
+			}
+
+			pcu := &v1alpha1.ProviderConfigUsage{}
+			err := kube.Get(ctx, client.ObjectKey{Name: string(mg.GetUID())}, pcu)
+			if !tc.wantUsage {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("trackProviderConfigUsage(...): want no ProviderConfigUsage, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("trackProviderConfigUsage(...): want a ProviderConfigUsage, got %v", err)
+			}
+
+			if got := pcu.GetProviderConfigReference().Name; got != pc.Name {
+				t.Errorf("trackProviderConfigUsage(...): want providerConfigRef %q, got %q", pc.Name, got)
+			}
+			if diff := cmp.Diff([]types.UID{tc.wantOwner}, owners(pcu)); diff != "" {
+				t.Errorf("trackProviderConfigUsage(...): -want owners, +got owners:\n%s", diff)
+			}
+			if c := metav1.GetControllerOf(pcu); c == nil || c.UID != tc.wantOwner {
+				t.Errorf("trackProviderConfigUsage(...): want controller %s, got %v", tc.wantOwner, c)
+			}
+		})
+	}
+}
+
+func owners(o metav1.Object) []types.UID {
+	var res []types.UID
+	for _, ref := range o.GetOwnerReferences() {
+		res = append(res, ref.UID)
+	}
+	return res
+}
@@ -0,0 +1,91 @@
+package clients
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// argocdConfigKey is the secret key holding the argocd CLI config file.
+	argocdConfigKey = "config"
+)
+
+// argocdConfig is the argocd CLI local config file.
+// See: https://argo-cd.readthedocs.io/en/stable/user-guide/commands/argocd_login/
+type argocdConfig struct {
+	Contexts       []argocdContext `json:"contexts"`
+	CurrentContext string          `json:"current-context"`
+	Servers        []argocdServer  `json:"servers"`
+	Users          []argocdUser    `json:"users"`
+}
+
+type argocdContext struct {
+	Name   string `json:"name"`
+	Server string `json:"server"`
+	User   string `json:"user"`
+}
+
+type argocdServer struct {
+	Server          string `json:"server"`
+	GRPCWeb         bool   `json:"grpc-web,omitempty"`
+	GRPCWebRootPath string `json:"grpc-web-root-path,omitempty"`
+	Insecure        bool   `json:"insecure,omitempty"`
+	PlainText       bool   `json:"plain-text,omitempty"`
+}
+
+type argocdUser struct {
+	Name      string `json:"name"`
+	AuthToken string `json:"auth-token,omitempty"`
+}
+
+// ArgoCDConfigOpts holds the settings of an argocd CLI config file.
+type ArgoCDConfigOpts struct {
+	ServerURL string
+	Token     string
+	Insecure  bool
+	GRPCWeb   bool
+}
+
+// NewArgoCDConfig returns an argocd CLI config file that uses the
+// supplied token to authenticate to the supplied server.
+func NewArgoCDConfig(opts ArgoCDConfigOpts) ([]byte, error) {
+	u, err := url.Parse(opts.ServerURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid argocd server url: %s", opts.ServerURL)
+	}
+	if len(u.Host) == 0 {
+		return nil, errors.Errorf("invalid argocd server url: %s", opts.ServerURL)
+	}
+
+	// the argocd CLI identifies servers by host[:port]
+	name := u.Host
+
+	cfg := argocdConfig{
+		Contexts: []argocdContext{
+			{Name: name, Server: name, User: name},
+		},
+		CurrentContext: name,
+		Servers: []argocdServer{
+			{
+				Server:    name,
+				GRPCWeb:   opts.GRPCWeb,
+				Insecure:  opts.Insecure,
+				PlainText: u.Scheme == "http",
+			},
+		},
+		Users: []argocdUser{
+			{Name: name, AuthToken: opts.Token},
+		},
+	}
+
+	// a root path is only supported over grpc-web
+	if rootPath := strings.Trim(u.Path, "/"); len(rootPath) > 0 {
+		cfg.Servers[0].GRPCWeb = true
+		cfg.Servers[0].GRPCWebRootPath = rootPath
+	}
+
+	return yaml.Marshal(&cfg)
+}
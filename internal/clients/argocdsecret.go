@@ -0,0 +1,63 @@
+package clients
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/accounts"
+)
+
+const (
+	errGetArgoCDSecret    = "cannot get argocd-secret"
+	errUpdateArgoCDSecret = "cannot update argocd-secret"
+)
+
+// NewArgoCDSecretStore returns a store giving access to the referenced
+// argocd-secret Secret.
+func NewArgoCDSecretStore(k client.Client, ref *xpv1.SecretReference) accounts.SecretStore {
+	return &argocdSecretStore{kube: k, ref: ref}
+}
+
+type argocdSecretStore struct {
+	kube client.Client
+	ref  *xpv1.SecretReference
+}
+
+func (s *argocdSecretStore) Get(ctx context.Context) (map[string][]byte, error) {
+	sec, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sec.Data, nil
+}
+
+func (s *argocdSecretStore) Update(ctx context.Context, fn func(data map[string][]byte) error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		sec, err := s.get(ctx)
+		if err != nil {
+			return err
+		}
+
+		if sec.Data == nil {
+			sec.Data = map[string][]byte{}
+		}
+		if err := fn(sec.Data); err != nil {
+			return err
+		}
+
+		return errors.Wrap(s.kube.Update(ctx, sec), errUpdateArgoCDSecret)
+	})
+}
+
+func (s *argocdSecretStore) get(ctx context.Context) (*corev1.Secret, error) {
+	sec := &corev1.Secret{}
+	err := s.kube.Get(ctx, types.NamespacedName{Namespace: s.ref.Namespace, Name: s.ref.Name}, sec)
+	return sec, errors.Wrap(err, errGetArgoCDSecret)
+}
@@ -0,0 +1,194 @@
+// Package encryption encrypts ArgoCD tokens with a public key, so that they
+// can be stored where anyone can read them (e.g. in a Git repository).
+//
+// Tokens are encrypted with AES-256-GCM using a random data key, which is in
+// turn encrypted with RSA-OAEP (SHA-256). The result is a base64 encoded JSON
+// envelope that also carries the fingerprints of the public key and of the
+// token, so it can be verified without the private key.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+const (
+	// AlgorithmRSAOAEP identifies the RSA-OAEP-256 + A256GCM envelope.
+	AlgorithmRSAOAEP = "RSA-OAEP-256+A256GCM"
+
+	fingerprintPrefix = "SHA256:"
+)
+
+// Envelope holds an encrypted token.
+type Envelope struct {
+	// Algorithm used to encrypt the token.
+	Algorithm string `json:"alg"`
+	// KeyID is the fingerprint of the public key the token is encrypted with.
+	KeyID string `json:"kid"`
+	// TokenFingerprint is the fingerprint of the plaintext token.
+	TokenFingerprint string `json:"tfp"`
+	// EncryptedKey is the RSA-OAEP encrypted AES data key.
+	EncryptedKey []byte `json:"ek"`
+	// Nonce of the AES-GCM encryption.
+	Nonce []byte `json:"iv"`
+	// Ciphertext is the AES-GCM encrypted token.
+	Ciphertext []byte `json:"ct"`
+}
+
+// ParsePublicKey parses a PEM encoded RSA public key, in either PKIX or PKCS1 format.
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("public key is not an RSA key")
+		}
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
+
+// KeyFingerprint returns the SHA256 fingerprint of the supplied public key.
+func KeyFingerprint(pub *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return fingerprint(der), nil
+}
+
+// TokenFingerprint returns the SHA256 fingerprint of the supplied token.
+func TokenFingerprint(token string) string {
+	return fingerprint([]byte(token))
+}
+
+// Encrypt encrypts the supplied token and returns the encoded envelope.
+func Encrypt(pub *rsa.PublicKey, token string) (string, error) {
+	kid, err := KeyFingerprint(pub)
+	if err != nil {
+		return "", err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	ek, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, key, nil)
+	if err != nil {
+		return "", err
+	}
+
+	env := Envelope{
+		Algorithm:        AlgorithmRSAOAEP,
+		KeyID:            kid,
+		TokenFingerprint: TokenFingerprint(token),
+		EncryptedKey:     ek,
+		Nonce:            nonce,
+		Ciphertext:       gcm.Seal(nil, nonce, []byte(token), []byte(kid)),
+	}
+
+	bin, err := json.Marshal(&env)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(bin), nil
+}
+
+// Parse decodes an encoded envelope; it does not need the private key.
+func Parse(s string) (Envelope, error) {
+	var env Envelope
+
+	bin, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return env, errors.New("token is not an encrypted envelope")
+	}
+
+	if err := json.Unmarshal(bin, &env); err != nil {
+		return env, errors.New("token is not an encrypted envelope")
+	}
+
+	if env.Algorithm != AlgorithmRSAOAEP {
+		return env, fmt.Errorf("unsupported encryption algorithm %q", env.Algorithm)
+	}
+
+	if len(env.KeyID) == 0 || len(env.EncryptedKey) == 0 || len(env.Ciphertext) == 0 {
+		return env, errors.New("incomplete encrypted envelope")
+	}
+
+	return env, nil
+}
+
+// Decrypt decrypts an encoded envelope with the supplied private key.
+func Decrypt(priv *rsa.PrivateKey, s string) (string, error) {
+	env, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, env.EncryptedKey, nil)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := gcm.Open(nil, env.Nonce, env.Ciphertext, []byte(env.KeyID))
+	if err != nil {
+		return "", err
+	}
+
+	if TokenFingerprint(string(token)) != env.TokenFingerprint {
+		return "", errors.New("token fingerprint mismatch")
+	}
+
+	return string(token), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func fingerprint(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fingerprintPrefix + base64.RawStdEncoding.EncodeToString(sum[:])
+}
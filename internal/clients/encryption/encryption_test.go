@@ -0,0 +1,97 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9." + strings.Repeat("x", 512) + ".c2lnbmF0dXJl"
+
+	enc, err := Encrypt(&priv.PublicKey, token)
+	if err != nil {
+		t.Fatalf("Encrypt(...): %v", err)
+	}
+	if strings.Contains(enc, token) {
+		t.Fatalf("Encrypt(...): plaintext token leaked")
+	}
+
+	env, err := Parse(enc)
+	if err != nil {
+		t.Fatalf("Parse(...): %v", err)
+	}
+
+	kid, _ := KeyFingerprint(&priv.PublicKey)
+	if env.KeyID != kid {
+		t.Errorf("Parse(...): want key id %s, got %s", kid, env.KeyID)
+	}
+	if env.TokenFingerprint != TokenFingerprint(token) {
+		t.Errorf("Parse(...): want token fingerprint %s, got %s", TokenFingerprint(token), env.TokenFingerprint)
+	}
+
+	got, err := Decrypt(priv, enc)
+	if err != nil {
+		t.Fatalf("Decrypt(...): %v", err)
+	}
+	if got != token {
+		t.Errorf("Decrypt(...): want %q, got %q", token, got)
+	}
+
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := Decrypt(other, enc); err == nil {
+		t.Errorf("Decrypt(...) with another key: want error, got nil")
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := map[string]string{
+		"Plaintext":  "eyJhbGciOiJIUzI1NiJ9.e30.sig",
+		"NotJSON":    "bm90IGpzb24=",
+		"Incomplete": "eyJhbGciOiJSU0EtT0FFUC0yNTYrQTI1NkdDTSJ9",
+	}
+
+	for name, s := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(s); err == nil {
+				t.Errorf("Parse(%q): want error, got nil", s)
+			}
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkix, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+
+	cases := map[string]struct {
+		data    []byte
+		wantErr bool
+	}{
+		"PKIX":    {data: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})},
+		"PKCS1":   {data: pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&priv.PublicKey)})},
+		"Private": {data: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}), wantErr: true},
+		"NoPEM":   {data: []byte("garbage"), wantErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParsePublicKey(tc.data)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ParsePublicKey(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
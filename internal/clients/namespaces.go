@@ -0,0 +1,13 @@
+package clients
+
+// IsNamespaceAllowed returns true if the supplied namespace matches one of
+// the supplied names or shell patterns; an empty list allows all namespaces.
+// Malformed patterns match no namespace.
+func IsNamespaceAllowed(allowed []string, ns string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	_, ok, _ := matchAny(allowed, ns)
+	return ok
+}
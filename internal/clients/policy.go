@@ -0,0 +1,84 @@
+package clients
+
+import (
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+)
+
+// CheckAccountPolicy returns an error explaining why the supplied policy
+// refuses a token for the supplied account expiring in the supplied
+// duration; zero means a non-expiring token.
+func CheckAccountPolicy(p *v1alpha1.AccountPolicy, account string, expiresIn time.Duration) error {
+	if p == nil {
+		return nil
+	}
+
+	pattern, ok, err := matchAny(p.DeniedAccounts, account)
+	if ok {
+		return errors.Errorf("account %s is denied by the ProviderConfig account policy (%s)", account, pattern)
+	}
+	if err != nil {
+		// an invalid deny pattern could be meant to match any account
+		return errors.Wrap(err, "invalid ProviderConfig account policy deniedAccounts")
+	}
+
+	if len(p.AllowedAccounts) > 0 {
+		_, ok, err := matchAny(p.AllowedAccounts, account)
+		if !ok && err != nil {
+			return errors.Wrap(err, "invalid ProviderConfig account policy allowedAccounts")
+		}
+		if !ok {
+			return errors.Errorf("account %s is not allowed by the ProviderConfig account policy", account)
+		}
+	}
+
+	if len(p.MaxTokenLifetime) > 0 {
+		max, err := time.ParseDuration(p.MaxTokenLifetime)
+		if err != nil {
+			return errors.Wrap(err, "invalid ProviderConfig account policy maxTokenLifetime")
+		}
+		if expiresIn <= 0 {
+			return errors.Errorf("non-expiring tokens are not allowed by the ProviderConfig account policy: set expiresIn up to %s", p.MaxTokenLifetime)
+		}
+		if expiresIn > max {
+			return errors.Errorf("expiresIn %s exceeds the maximum token lifetime %s of the ProviderConfig account policy", expiresIn, p.MaxTokenLifetime)
+		}
+	}
+
+	if expiresIn <= 0 && p.AllowNonExpiringTokens != nil && !*p.AllowNonExpiringTokens {
+		return errors.New("non-expiring tokens are not allowed by the ProviderConfig account policy: set expiresIn")
+	}
+
+	return nil
+}
+
+// IsValidPattern returns true if the supplied name or shell pattern is
+// well formed.
+func IsValidPattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// matchAny returns the first of the supplied names or shell patterns
+// matching s. Malformed patterns never match: the error reports the first
+// one.
+func matchAny(patterns []string, s string) (string, bool, error) {
+	var bad error
+	for _, el := range patterns {
+		ok, err := path.Match(el, s)
+		if err != nil {
+			if bad == nil {
+				bad = errors.Wrapf(err, "pattern %q", el)
+			}
+			continue
+		}
+		if ok {
+			return el, true, nil
+		}
+	}
+	return "", false, bad
+}
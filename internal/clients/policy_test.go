@@ -0,0 +1,168 @@
+package clients
+
+import (
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+)
+
+func TestCheckAccountPolicy(t *testing.T) {
+	no := false
+
+	cases := map[string]struct {
+		policy    *v1alpha1.AccountPolicy
+		account   string
+		expiresIn time.Duration
+		wantErr   bool
+	}{
+		"NoPolicy": {
+			account: "ci",
+		},
+		"EmptyPolicy": {
+			policy:  &v1alpha1.AccountPolicy{},
+			account: "ci",
+		},
+		"Allowed": {
+			policy:  &v1alpha1.AccountPolicy{AllowedAccounts: []string{"dashboard", "ci-*"}},
+			account: "ci-runner",
+		},
+		"NotAllowed": {
+			policy:  &v1alpha1.AccountPolicy{AllowedAccounts: []string{"dashboard", "ci-*"}},
+			account: "admin",
+			wantErr: true,
+		},
+		"Denied": {
+			policy:  &v1alpha1.AccountPolicy{DeniedAccounts: []string{"admin"}},
+			account: "admin",
+			wantErr: true,
+		},
+		"DeniedByPattern": {
+			policy:  &v1alpha1.AccountPolicy{DeniedAccounts: []string{"adm*"}},
+			account: "admin",
+			wantErr: true,
+		},
+		"NotDenied": {
+			policy:  &v1alpha1.AccountPolicy{DeniedAccounts: []string{"adm*"}},
+			account: "ci",
+		},
+		"DeniedWinsOverAllowed": {
+			policy:  &v1alpha1.AccountPolicy{AllowedAccounts: []string{"*"}, DeniedAccounts: []string{"admin"}},
+			account: "admin",
+			wantErr: true,
+		},
+		"InvalidDeniedPattern": {
+			policy:  &v1alpha1.AccountPolicy{DeniedAccounts: []string{"adm[", "root"}},
+			account: "ci",
+			wantErr: true,
+		},
+		"DeniedWithInvalidPattern": {
+			policy:  &v1alpha1.AccountPolicy{DeniedAccounts: []string{"adm[", "ci"}},
+			account: "ci",
+			wantErr: true,
+		},
+		"InvalidAllowedPattern": {
+			policy:  &v1alpha1.AccountPolicy{AllowedAccounts: []string{"ci-["}},
+			account: "ci-runner",
+			wantErr: true,
+		},
+		"AllowedWithInvalidPattern": {
+			policy:  &v1alpha1.AccountPolicy{AllowedAccounts: []string{"ci-[", "ci-*"}},
+			account: "ci-runner",
+		},
+		"WithinMaxLifetime": {
+			policy:    &v1alpha1.AccountPolicy{MaxTokenLifetime: "720h"},
+			account:   "ci",
+			expiresIn: 24 * time.Hour,
+		},
+		"AtMaxLifetime": {
+			policy:    &v1alpha1.AccountPolicy{MaxTokenLifetime: "720h"},
+			account:   "ci",
+			expiresIn: 720 * time.Hour,
+		},
+		"BeyondMaxLifetime": {
+			policy:    &v1alpha1.AccountPolicy{MaxTokenLifetime: "720h"},
+			account:   "ci",
+			expiresIn: 721 * time.Hour,
+			wantErr:   true,
+		},
+		"NonExpiringWithMaxLifetime": {
+			policy:  &v1alpha1.AccountPolicy{MaxTokenLifetime: "720h"},
+			account: "ci",
+			wantErr: true,
+		},
+		"InvalidMaxLifetime": {
+			policy:    &v1alpha1.AccountPolicy{MaxTokenLifetime: "a month"},
+			account:   "ci",
+			expiresIn: time.Hour,
+			wantErr:   true,
+		},
+		"NonExpiringAllowedByDefault": {
+			policy:  &v1alpha1.AccountPolicy{AllowedAccounts: []string{"ci"}},
+			account: "ci",
+		},
+		"NonExpiringNotAllowed": {
+			policy:  &v1alpha1.AccountPolicy{AllowNonExpiringTokens: &no},
+			account: "ci",
+			wantErr: true,
+		},
+		"ExpiringWithNonExpiringNotAllowed": {
+			policy:    &v1alpha1.AccountPolicy{AllowNonExpiringTokens: &no},
+			account:   "ci",
+			expiresIn: time.Hour,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := CheckAccountPolicy(tc.policy, tc.account, tc.expiresIn)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("CheckAccountPolicy(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestIsNamespaceAllowed(t *testing.T) {
+	cases := map[string]struct {
+		allowed []string
+		ns      string
+		want    bool
+	}{
+		"NoRestrictions": {
+			ns:   "kube-system",
+			want: true,
+		},
+		"Name": {
+			allowed: []string{"argocd"},
+			ns:      "argocd",
+			want:    true,
+		},
+		"Pattern": {
+			allowed: []string{"argocd", "team-*"},
+			ns:      "team-a",
+			want:    true,
+		},
+		"NotAllowed": {
+			allowed: []string{"argocd", "team-*"},
+			ns:      "kube-system",
+		},
+		"InvalidPattern": {
+			allowed: []string{"team-["},
+			ns:      "team-[",
+		},
+		"AllowedWithInvalidPattern": {
+			allowed: []string{"team-[", "team-*"},
+			ns:      "team-a",
+			want:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := IsNamespaceAllowed(tc.allowed, tc.ns); got != tc.want {
+				t.Errorf("IsNamespaceAllowed(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package clients
+
+import (
+	"context"
+	"crypto/rsa"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/encryption"
+)
+
+const (
+	kindConfigMap = "ConfigMap"
+
+	errGetPublicKey   = "cannot get public key"
+	errParsePublicKey = "cannot parse public key"
+)
+
+// GetPublicKey returns the RSA public key referenced by the supplied ref.
+func GetPublicKey(ctx context.Context, k client.Client, ref *endpointsv1alpha1.PublicKeyReference) (*rsa.PublicKey, error) {
+	if ref == nil {
+		return nil, errors.New("no public key referenced")
+	}
+
+	nn := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+
+	var data []byte
+	if ref.Kind == kindConfigMap {
+		cm := &corev1.ConfigMap{}
+		if err := k.Get(ctx, nn, cm); err != nil {
+			return nil, errors.Wrap(err, errGetPublicKey)
+		}
+		data = []byte(cm.Data[ref.Key])
+	} else {
+		s := &corev1.Secret{}
+		if err := k.Get(ctx, nn, s); err != nil {
+			return nil, errors.Wrap(err, errGetPublicKey)
+		}
+		data = s.Data[ref.Key]
+	}
+
+	pub, err := encryption.ParsePublicKey(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s %s/%s", errParsePublicKey, ref.Namespace, ref.Name)
+	}
+
+	return pub, nil
+}
@@ -0,0 +1,131 @@
+package clients
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+)
+
+// A ReferenceIndex records the objects referenced by the ProviderConfigs:
+// the accounts ConfigMap, the argocd-server Service and its parameters
+// ConfigMap. Watches use it to ignore all the other ConfigMaps and Services
+// of the cluster. Events received before the ProviderConfigs have been seen
+// are ignored, which is fine as all the resources are reconciled at startup
+// anyway.
+type ReferenceIndex struct {
+	mu       sync.RWMutex
+	refs     map[string][]types.NamespacedName
+	services map[string]*v1alpha1.ServiceReference
+}
+
+// NewReferenceIndex returns an empty ReferenceIndex.
+func NewReferenceIndex() *ReferenceIndex {
+	return &ReferenceIndex{
+		refs:     map[string][]types.NamespacedName{},
+		services: map[string]*v1alpha1.ServiceReference{},
+	}
+}
+
+// Track returns a predicate recording the objects referenced by the
+// ProviderConfigs it sees; it lets all the events through. It must come
+// first on a ProviderConfig watch, as predicates stop at the first miss.
+func (i *ReferenceIndex) Track() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			i.set(e.Object)
+			return true
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			i.set(e.ObjectNew)
+			return true
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			i.mu.Lock()
+			defer i.mu.Unlock()
+			delete(i.refs, e.Object.GetName())
+			delete(i.services, e.Object.GetName())
+			return true
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			i.set(e.Object)
+			return true
+		},
+	}
+}
+
+// Referenced returns a predicate letting through the events of the
+// ConfigMaps and Services referenced by a ProviderConfig; on updates either
+// the old or the new object must be, so that a Service no longer selected
+// is noticed.
+func (i *ReferenceIndex) Referenced() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return i.IsReferenced(e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return i.IsReferenced(e.ObjectOld) || i.IsReferenced(e.ObjectNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return i.IsReferenced(e.Object)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return i.IsReferenced(e.Object)
+		},
+	}
+}
+
+// IsReferenced returns true if the supplied ConfigMap or Service is
+// referenced by a ProviderConfig.
+func (i *ReferenceIndex) IsReferenced(o client.Object) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if _, ok := o.(*corev1.Service); ok {
+		for _, ref := range i.services {
+			if IsServerService(ref, o) {
+				return true
+			}
+		}
+		return false
+	}
+
+	key := types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}
+	for _, refs := range i.refs {
+		for _, ref := range refs {
+			if ref == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (i *ReferenceIndex) set(o client.Object) {
+	pc, ok := o.(*v1alpha1.ProviderConfig)
+	if !ok {
+		return
+	}
+
+	refs := []types.NamespacedName{}
+	if ref := pc.Spec.AccountsConfigMapRef; ref != nil {
+		refs = append(refs, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name})
+	}
+	if ref := pc.Spec.ServerRef; ref != nil {
+		refs = append(refs, types.NamespacedName{Namespace: ref.Namespace, Name: paramsConfigMapName(ref)})
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.refs[pc.Name] = refs
+	if ref := pc.Spec.ServerRef; ref != nil {
+		i.services[pc.Name] = ref.DeepCopy()
+	} else {
+		delete(i.services, pc.Name)
+	}
+}
@@ -0,0 +1,70 @@
+package clients
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+)
+
+func TestReferenceIndex(t *testing.T) {
+	pc := &v1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	pc.Spec.AccountsConfigMapRef = &v1alpha1.ConfigMapReference{Name: "argocd-cm", Namespace: "argocd"}
+	pc.Spec.ServerRef = &v1alpha1.ServiceReference{Namespace: "argocd"}
+
+	cm := func(ns, name string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	}
+	svc := func(ns, name string, lbls map[string]string) *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, Labels: lbls}}
+	}
+	server := map[string]string{"app.kubernetes.io/name": "argocd-server"}
+
+	idx := NewReferenceIndex()
+	if idx.IsReferenced(cm("argocd", "argocd-cm")) {
+		t.Errorf("IsReferenced(...): want false before any ProviderConfig is seen")
+	}
+
+	if !idx.Track().Create(event.CreateEvent{Object: pc}) {
+		t.Errorf("Track().Create(...): want all events let through")
+	}
+
+	cases := map[string]struct {
+		o    client.Object
+		want bool
+	}{
+		"AccountsConfigMap":     {o: cm("argocd", "argocd-cm"), want: true},
+		"ServerParams":          {o: cm("argocd", defaultParamsCM), want: true},
+		"OtherNamespace":        {o: cm("default", "argocd-cm")},
+		"Unrelated":             {o: cm("argocd", "argocd-rbac-cm")},
+		"ServerService":         {o: svc("argocd", "argocd-server", server), want: true},
+		"ServiceOtherNamespace": {o: svc("default", "argocd-server", server)},
+		"UnrelatedService":      {o: svc("argocd", "argocd-redis", map[string]string{"app.kubernetes.io/name": "argocd-redis"})},
+		"ServiceNamedAsParams":  {o: svc("argocd", defaultParamsCM, nil)},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := idx.IsReferenced(tc.o); got != tc.want {
+				t.Errorf("IsReferenced(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+
+	// a Service no longer selected is let through
+	upd := event.UpdateEvent{ObjectOld: svc("argocd", "argocd-server", server), ObjectNew: svc("argocd", "argocd-server", nil)}
+	if !idx.Referenced().Update(upd) {
+		t.Errorf("Referenced().Update(...): want the update of a Service no longer selected let through")
+	}
+	if idx.Referenced().Create(event.CreateEvent{Object: svc("argocd", "argocd-redis", nil)}) {
+		t.Errorf("Referenced().Create(...): want the events of unrelated Services filtered out")
+	}
+
+	idx.Track().Delete(event.DeleteEvent{Object: pc})
+	if idx.IsReferenced(cm("argocd", "argocd-cm")) || idx.IsReferenced(svc("argocd", "argocd-server", server)) {
+		t.Errorf("IsReferenced(...): want false once the ProviderConfig is deleted")
+	}
+}
@@ -0,0 +1,163 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TemplateData is the data model the endpoint secret templates are rendered over.
+type TemplateData struct {
+	// Token is the ArgoCD account token.
+	Token string
+	// TokenID is the token identifier (jti claim).
+	TokenID string
+	// ServerURL is the ArgoCD server url.
+	ServerURL string
+	// Account is the ArgoCD account name.
+	Account string
+	// ExpiresAt is the token expiration time (RFC3339); empty if the token never expires.
+	ExpiresAt string
+	// CA is the PEM encoded CA certificate of the ArgoCD server, if any.
+	CA string
+}
+
+// sampleTemplateData is used to validate templates before a token is issued.
+var sampleTemplateData = TemplateData{
+	Token:     "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.e30.c2lnbmF0dXJl",
+	TokenID:   "00000000-0000-0000-0000-000000000000",
+	ServerURL: "https://argocd-server.argo-system.svc:443",
+	Account:   "account",
+	ExpiresAt: time.Unix(0, 0).UTC().Format(time.RFC3339),
+	CA:        "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n",
+}
+
+var templateFuncs = template.FuncMap{
+	"b64enc": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"toJson": func(v interface{}) (string, error) {
+		bin, err := json.Marshal(v)
+		return string(bin), err
+	},
+	"quote": func(s string) string {
+		bin, _ := json.Marshal(s)
+		return string(bin)
+	},
+	"trimSuffix": func(suffix, s string) string {
+		return strings.TrimSuffix(s, suffix)
+	},
+}
+
+// RenderTemplates renders each of the supplied templates over the supplied data.
+func RenderTemplates(tpls map[string]string, data TemplateData) (map[string][]byte, error) {
+	res := make(map[string][]byte, len(tpls))
+
+	for _, key := range sortedKeys(tpls) {
+		tpl, err := template.New(key).
+			Option("missingkey=error").
+			Funcs(templateFuncs).
+			Parse(tpls[key])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse template for key %s", key)
+		}
+
+		buf := bytes.Buffer{}
+		if err := tpl.Execute(&buf, data); err != nil {
+			return nil, errors.Wrapf(err, "cannot render template for key %s", key)
+		}
+
+		res[key] = buf.Bytes()
+	}
+
+	return res, nil
+}
+
+// ValidateTemplates checks that the supplied templates can be rendered.
+func ValidateTemplates(tpls map[string]string) error {
+	_, err := RenderTemplates(tpls, sampleTemplateData)
+	return err
+}
+
+// TemplatesUsing returns the keys of the supplied templates referencing
+// the named field of the data model.
+func TemplatesUsing(tpls map[string]string, field string) []string {
+	res := []string{}
+	for _, key := range sortedKeys(tpls) {
+		tpl, err := template.New(key).Funcs(templateFuncs).Parse(tpls[key])
+		if err != nil {
+			continue
+		}
+		for _, t := range tpl.Templates() {
+			if t.Tree != nil && usesField(t.Tree.Root, field) {
+				res = append(res, key)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// usesField returns true if the supplied template node references the
+// named field, e.g. .Token or $.Token.
+func usesField(node parse.Node, field string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, el := range n.Nodes {
+			if usesField(el, field) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return usesField(n.Pipe, field)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if usesField(cmd, field) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if usesField(arg, field) {
+				return true
+			}
+		}
+	case *parse.IfNode:
+		return usesField(n.Pipe, field) || usesField(n.List, field) || usesField(n.ElseList, field)
+	case *parse.RangeNode:
+		return usesField(n.Pipe, field) || usesField(n.List, field) || usesField(n.ElseList, field)
+	case *parse.WithNode:
+		return usesField(n.Pipe, field) || usesField(n.List, field) || usesField(n.ElseList, field)
+	case *parse.TemplateNode:
+		return usesField(n.Pipe, field)
+	case *parse.FieldNode:
+		return len(n.Ident) > 0 && n.Ident[0] == field
+	case *parse.VariableNode:
+		return len(n.Ident) > 1 && n.Ident[1] == field
+	case *parse.ChainNode:
+		return usesField(n.Node, field)
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
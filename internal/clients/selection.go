@@ -0,0 +1,111 @@
+package clients
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+)
+
+const (
+	// DefaultProviderConfigName is the ProviderConfig, or the
+	// ProviderConfigBinding of a namespaced resource, used when no
+	// providerConfigRef is given.
+	DefaultProviderConfigName = "default"
+
+	errInvalidProviderConfigSelector = "invalid providerConfigSelector"
+	errListProviderConfigs           = "cannot list ProviderConfigs"
+	errListProviderConfigBindings    = "cannot list ProviderConfigBindings"
+	errNoProviderConfigSelected      = "no ProviderConfig matches providerConfigSelector"
+	errNoProviderConfigBindingSel    = "no ProviderConfigBinding matches providerConfigSelector"
+)
+
+// ProviderConfigName returns the name of the ProviderConfig, or of the
+// ProviderConfigBinding, referenced by the supplied managed resource;
+// the default one if no reference is given.
+func ProviderConfigName(mg resource.Managed) string {
+	if ref := mg.GetProviderConfigReference(); ref != nil && len(ref.Name) > 0 {
+		return ref.Name
+	}
+	return DefaultProviderConfigName
+}
+
+// SelectProviderConfig returns the name of the ProviderConfig, or of the
+// ProviderConfigBinding in the namespace of a namespaced resource, matching
+// the supplied selector. The currently referenced one is kept if it still
+// matches, so that the selection is stable; otherwise the first by name.
+func SelectProviderConfig(ctx context.Context, k client.Client, mg resource.Managed, sel *metav1.LabelSelector) (string, error) {
+	s, err := metav1.LabelSelectorAsSelector(sel)
+	if err != nil {
+		return "", errors.Wrap(err, errInvalidProviderConfigSelector)
+	}
+
+	names := []string{}
+	if ns := mg.GetNamespace(); len(ns) > 0 {
+		list := &v1alpha1.ProviderConfigBindingList{}
+		if err := k.List(ctx, list, client.InNamespace(ns), client.MatchingLabelsSelector{Selector: s}); err != nil {
+			return "", errors.Wrap(err, errListProviderConfigBindings)
+		}
+		for _, el := range list.Items {
+			if el.DeletionTimestamp == nil {
+				names = append(names, el.Name)
+			}
+		}
+		if len(names) == 0 {
+			return "", errors.New(errNoProviderConfigBindingSel)
+		}
+	} else {
+		list := &v1alpha1.ProviderConfigList{}
+		if err := k.List(ctx, list, client.MatchingLabelsSelector{Selector: s}); err != nil {
+			return "", errors.Wrap(err, errListProviderConfigs)
+		}
+		for _, el := range list.Items {
+			if el.DeletionTimestamp == nil {
+				names = append(names, el.Name)
+			}
+		}
+		if len(names) == 0 {
+			return "", errors.New(errNoProviderConfigSelected)
+		}
+	}
+
+	if ref := mg.GetProviderConfigReference(); ref != nil && contains(names, ref.Name) {
+		return ref.Name, nil
+	}
+
+	sort.Strings(names)
+	return names[0], nil
+}
+
+// ResolveProviderConfigReference sets the providerConfigRef of the supplied
+// managed resource to the ProviderConfig matching the supplied selector;
+// it returns true if the reference changed.
+func ResolveProviderConfigReference(ctx context.Context, k client.Client, mg resource.Managed, sel *metav1.LabelSelector) (bool, error) {
+	name, err := SelectProviderConfig(ctx, k, mg, sel)
+	if err != nil {
+		return false, err
+	}
+
+	if ref := mg.GetProviderConfigReference(); ref != nil && ref.Name == name {
+		return false, nil
+	}
+
+	mg.SetProviderConfigReference(&xpv1.Reference{Name: name})
+	return true, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, el := range list {
+		if el == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/accounts"
+)
+
+const (
+	defaultServerSelector = "app.kubernetes.io/name=argocd-server"
+	defaultParamsCM       = "argocd-cmd-params-cm"
+	paramServerInsecure   = "server.insecure"
+	paramServerRootPath   = "server.rootpath"
+
+	errNoServer        = "either serverUrl or serverRef must be specified"
+	errServerURLAndRef = "serverUrl and serverRef are mutually exclusive"
+	errGetService      = "cannot get argocd-server Service"
+	errGetParamsCM     = "cannot get argocd-server parameters ConfigMap"
+	errFmtServiceCount = "expected exactly one argocd-server Service in namespace %s, found %d"
+	errFmtNoPort       = "argocd-server Service %s/%s has no port named %s"
+)
+
+// ResolveServerURL returns the url of the ArgoCD server of the supplied
+// ProviderConfig, following its Service reference if any.
+func ResolveServerURL(ctx context.Context, k client.Client, pc *v1alpha1.ProviderConfig) (string, error) {
+	ref := pc.Spec.ServerRef
+	if ref != nil && len(pc.Spec.ServerUrl) > 0 {
+		return "", errors.New(errServerURLAndRef)
+	}
+	if ref == nil {
+		if len(pc.Spec.ServerUrl) == 0 {
+			return "", errors.New(errNoServer)
+		}
+		return accounts.NormalizeServerURL(pc.Spec.ServerUrl, pc.Spec.RootPath)
+	}
+
+	svc, err := getServerService(ctx, k, ref)
+	if err != nil {
+		return "", err
+	}
+
+	port, err := serverPort(svc, ref.PortName)
+	if err != nil {
+		return "", err
+	}
+
+	params, err := getServerParams(ctx, k, ref)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := "https"
+	if insecure, err := strconv.ParseBool(strings.TrimSpace(params[paramServerInsecure])); err == nil && insecure {
+		scheme = "http"
+	}
+
+	rootPath := pc.Spec.RootPath
+	if len(rootPath) == 0 {
+		rootPath = strings.TrimSpace(params[paramServerRootPath])
+	}
+
+	return accounts.NormalizeServerURL(fmt.Sprintf("%s://%s.%s.svc:%d", scheme, svc.Name, svc.Namespace, port), rootPath)
+}
+
+// IsServerService returns true if the supplied object is selected by the
+// supplied Service reference.
+func IsServerService(ref *v1alpha1.ServiceReference, o metav1.Object) bool {
+	if ref == nil || ref.Namespace != o.GetNamespace() {
+		return false
+	}
+
+	if len(ref.Name) > 0 {
+		return ref.Name == o.GetName()
+	}
+
+	sel, err := serverSelector(ref)
+	if err != nil {
+		return false
+	}
+	return sel.Matches(labels.Set(o.GetLabels()))
+}
+
+// IsServerParamsConfigMap returns true if the supplied object is the
+// argocd-server parameters ConfigMap of the supplied Service reference.
+func IsServerParamsConfigMap(ref *v1alpha1.ServiceReference, o metav1.Object) bool {
+	return ref != nil && ref.Namespace == o.GetNamespace() && paramsConfigMapName(ref) == o.GetName()
+}
+
+// ProviderConfigsForServer returns the names of the ProviderConfigs whose
+// server url depends on the supplied Service or ConfigMap.
+func ProviderConfigsForServer(ctx context.Context, k client.Client, o client.Object) []string {
+	var match func(ref *v1alpha1.ServiceReference) bool
+	switch o.(type) {
+	case *corev1.Service:
+		match = func(ref *v1alpha1.ServiceReference) bool { return IsServerService(ref, o) }
+	case *corev1.ConfigMap:
+		match = func(ref *v1alpha1.ServiceReference) bool { return IsServerParamsConfigMap(ref, o) }
+	default:
+		return nil
+	}
+
+	list := &v1alpha1.ProviderConfigList{}
+	if err := k.List(ctx, list); err != nil {
+		return nil
+	}
+
+	res := []string{}
+	for _, pc := range list.Items {
+		if match(pc.Spec.ServerRef) {
+			res = append(res, pc.Name)
+		}
+	}
+	return res
+}
+
+func getServerService(ctx context.Context, k client.Client, ref *v1alpha1.ServiceReference) (*corev1.Service, error) {
+	if len(ref.Name) > 0 {
+		svc := &corev1.Service{}
+		err := k.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, svc)
+		return svc, errors.Wrap(err, errGetService)
+	}
+
+	sel, err := serverSelector(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &corev1.ServiceList{}
+	err = k.List(ctx, list, client.InNamespace(ref.Namespace), client.MatchingLabelsSelector{Selector: sel})
+	if err != nil {
+		return nil, errors.Wrap(err, errGetService)
+	}
+
+	if len(list.Items) != 1 {
+		return nil, errors.Errorf(errFmtServiceCount, ref.Namespace, len(list.Items))
+	}
+
+	return &list.Items[0], nil
+}
+
+func serverSelector(ref *v1alpha1.ServiceReference) (labels.Selector, error) {
+	if ref.Selector == nil {
+		return labels.Parse(defaultServerSelector)
+	}
+
+	sel, err := metav1.LabelSelectorAsSelector(ref.Selector)
+	return sel, errors.Wrap(err, "invalid argocd-server Service selector")
+}
+
+// serverPort returns the Service port with the supplied name, or the
+// 'https' one, or the 'http' one, or the first one.
+func serverPort(svc *corev1.Service, name string) (int32, error) {
+	find := func(name string) (int32, bool) {
+		for _, p := range svc.Spec.Ports {
+			if p.Name == name {
+				return p.Port, true
+			}
+		}
+		return 0, false
+	}
+
+	if len(name) > 0 {
+		if port, ok := find(name); ok {
+			return port, nil
+		}
+		return 0, errors.Errorf(errFmtNoPort, svc.Namespace, svc.Name, name)
+	}
+
+	for _, name := range []string{"https", "http"} {
+		if port, ok := find(name); ok {
+			return port, nil
+		}
+	}
+
+	if len(svc.Spec.Ports) == 0 {
+		return 0, errors.Errorf("argocd-server Service %s/%s has no ports", svc.Namespace, svc.Name)
+	}
+
+	return svc.Spec.Ports[0].Port, nil
+}
+
+// getServerParams returns the argocd-server parameters; a missing
+// parameters ConfigMap means the default setup.
+func getServerParams(ctx context.Context, k client.Client, ref *v1alpha1.ServiceReference) (map[string]string, error) {
+	cm := &corev1.ConfigMap{}
+	err := k.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: paramsConfigMapName(ref)}, cm)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return map[string]string{}, nil
+		}
+		return nil, errors.Wrap(err, errGetParamsCM)
+	}
+
+	return cm.Data, nil
+}
+
+func paramsConfigMapName(ref *v1alpha1.ServiceReference) string {
+	if len(ref.ParamsConfigMapName) > 0 {
+		return ref.ParamsConfigMapName
+	}
+	return defaultParamsCM
+}
@@ -0,0 +1,315 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/vault"
+)
+
+const (
+	defaultVaultPathTemplate = "krateo/argocd/{{ .Name }}"
+
+	// serviceAccountTokenFile is the token used with the Vault Kubernetes auth method.
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	vaultMetaManagedBy   = "managed-by"
+	vaultMetaEndpointUID = "endpoint-uid"
+	vaultMetaTokenKey    = "token-key"
+)
+
+// A Sink stores the content of an endpoint secret.
+type Sink interface {
+	// Token returns the stored token; an empty string if nothing is stored.
+	Token(ctx context.Context) (string, error)
+
+	// IsUpToDate returns true if the stored content is the one described
+	// by the supplied options.
+	IsUpToDate(ctx context.Context, opts CreateSecretOpts) (bool, error)
+
+	// Write stores the content described by the supplied options.
+	Write(ctx context.Context, opts CreateSecretOpts) error
+
+	// Delete removes the stored content.
+	Delete(ctx context.Context) error
+
+	// String describes where the content is stored.
+	String() string
+}
+
+// NewKubernetesSink returns a Sink writing to the referenced Kubernetes secret.
+func NewKubernetesSink(k client.Client, ref xpv1.SecretReference, owner metav1.Object) Sink {
+	return &kubernetesSink{kube: k, ref: ref, owner: owner}
+}
+
+type kubernetesSink struct {
+	kube  client.Client
+	ref   xpv1.SecretReference
+	owner metav1.Object
+}
+
+func (s *kubernetesSink) Token(ctx context.Context) (string, error) {
+	sec, err := GetEndpointSecret(ctx, s.kube, &s.ref, s.owner)
+	if err != nil {
+		return "", err
+	}
+	return TokenFromSecret(sec), nil
+}
+
+func (s *kubernetesSink) IsUpToDate(ctx context.Context, opts CreateSecretOpts) (bool, error) {
+	got, err := GetEndpointSecret(ctx, s.kube, &s.ref, s.owner)
+	if err != nil {
+		return false, err
+	}
+
+	want, err := NewEndpointSecret(s.opts(opts))
+	if err != nil {
+		return false, err
+	}
+
+	return IsEndpointSecretUpToDate(got, want), nil
+}
+
+func (s *kubernetesSink) Write(ctx context.Context, opts CreateSecretOpts) error {
+	return CreateEndpointSecret(ctx, s.kube, s.opts(opts))
+}
+
+func (s *kubernetesSink) Delete(ctx context.Context) error {
+	return DeleteEndpointSecret(ctx, s.kube, &s.ref, s.owner)
+}
+
+func (s *kubernetesSink) String() string {
+	return fmt.Sprintf("secret %s/%s", s.ref.Namespace, s.ref.Name)
+}
+
+func (s *kubernetesSink) opts(opts CreateSecretOpts) CreateSecretOpts {
+	ref := s.ref
+	opts.SecretRef = &ref
+	opts.Owner = s.owner
+	return opts
+}
+
+// NewVaultSink returns a Sink writing to the Vault KV v2 secrets engine
+// configured by the referenced VaultConfig.
+func NewVaultSink(ctx context.Context, k client.Client, ref *xpv1.Reference, owner metav1.Object, account string) (Sink, error) {
+	if ref == nil {
+		return nil, errors.New("no vault config referenced")
+	}
+
+	vc := &v1alpha1.VaultConfig{}
+	if err := k.Get(ctx, types.NamespacedName{Name: ref.Name}, vc); err != nil {
+		return nil, errors.Wrap(err, "cannot get referenced VaultConfig")
+	}
+
+	path, err := renderVaultPath(vc.Spec.PathTemplate, owner.GetName(), account)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := vault.ClientOptions{
+		Address:   vc.Spec.Address,
+		Namespace: vc.Spec.Namespace,
+		MountPath: vc.Spec.MountPath,
+	}
+
+	if caRef := vc.Spec.CertificateAuthorityRef; caRef != nil {
+		ca, err := getSecret(ctx, k, caRef)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot get vault CA certificate")
+		}
+		opts.CACert = []byte(ca)
+	}
+
+	switch auth := vc.Spec.Auth; auth.Method {
+	case v1alpha1.VaultAuthToken:
+		opts.Token, err = getSecret(ctx, k, auth.TokenSecretRef)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot get vault token")
+		}
+	case v1alpha1.VaultAuthKubernetes:
+		if auth.Kubernetes == nil {
+			return nil, errors.New("missing vault kubernetes auth settings")
+		}
+		jwt, err := ioutil.ReadFile(serviceAccountTokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read service account token")
+		}
+		opts.KubernetesAuthMount = auth.Kubernetes.MountPath
+		opts.KubernetesAuthRole = auth.Kubernetes.Role
+		opts.KubernetesAuthJWT = strings.TrimSpace(string(jwt))
+	default:
+		return nil, errors.Errorf("vault auth method %s is not supported", auth.Method)
+	}
+
+	cli, err := vault.NewClient(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vaultSink{cli: cli, path: path, owner: owner}, nil
+}
+
+type vaultSink struct {
+	cli   *vault.Client
+	path  string
+	owner metav1.Object
+}
+
+func (s *vaultSink) Token(ctx context.Context) (string, error) {
+	meta, err := s.metadata(ctx)
+	if err != nil || meta == nil {
+		return "", err
+	}
+
+	data, err := s.cli.Read(ctx, s.path)
+	if err != nil {
+		if errors.Is(err, vault.ErrNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	key := tokenKey
+	if k := meta[vaultMetaTokenKey]; len(k) > 0 {
+		key = k
+	}
+
+	return data[key], nil
+}
+
+func (s *vaultSink) IsUpToDate(ctx context.Context, opts CreateSecretOpts) (bool, error) {
+	meta, err := s.metadata(ctx)
+	if err != nil || meta == nil {
+		return false, err
+	}
+
+	want, err := s.data(opts)
+	if err != nil {
+		return false, err
+	}
+
+	got, err := s.cli.Read(ctx, s.path)
+	if err != nil {
+		if errors.Is(err, vault.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return cmp.Equal(got, want, cmpopts.EquateEmpty()), nil
+}
+
+func (s *vaultSink) Write(ctx context.Context, opts CreateSecretOpts) error {
+	if _, err := s.metadata(ctx); err != nil {
+		return err
+	}
+
+	data, err := s.data(opts)
+	if err != nil {
+		return err
+	}
+
+	// the ownership marker goes first: a path holding our data without
+	// it could no longer be told apart from a foreign one.
+	tk, _ := secretKeys(opts.Template)
+	err = s.cli.WriteMetadata(ctx, s.path, map[string]string{
+		vaultMetaManagedBy:   managedByValue,
+		vaultMetaEndpointUID: string(s.owner.GetUID()),
+		vaultMetaTokenKey:    tk,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.cli.Write(ctx, s.path, data)
+}
+
+func (s *vaultSink) Delete(ctx context.Context) error {
+	meta, err := s.metadata(ctx)
+	if err != nil || meta == nil {
+		return err
+	}
+
+	return s.cli.Delete(ctx, s.path)
+}
+
+func (s *vaultSink) String() string {
+	return fmt.Sprintf("vault path %s", s.path)
+}
+
+// metadata returns the custom metadata of the secret, nil if the secret
+// does not exist; an error satisfying IsSecretNotOwned is returned if the
+// secret has not been written by the owner of this sink.
+func (s *vaultSink) metadata(ctx context.Context) (map[string]string, error) {
+	meta, err := s.cli.ReadMetadata(ctx, s.path)
+	if err != nil {
+		if errors.Is(err, vault.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if meta[vaultMetaManagedBy] != managedByValue || meta[vaultMetaEndpointUID] != string(s.owner.GetUID()) {
+		return nil, errors.Wrapf(errSecretNotOwned, "vault path %s already exists", s.path)
+	}
+
+	return meta, nil
+}
+
+// data returns the vault secret data described by the supplied options.
+func (s *vaultSink) data(opts CreateSecretOpts) (map[string]string, error) {
+	opts.SecretRef = &xpv1.SecretReference{Name: s.path}
+	opts.Owner = s.owner
+
+	sec, err := NewEndpointSecret(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(sec.Data))
+	for k, v := range sec.Data {
+		res[k] = string(v)
+	}
+	return res, nil
+}
+
+func renderVaultPath(tpl, name, account string) (string, error) {
+	if len(tpl) == 0 {
+		tpl = defaultVaultPathTemplate
+	}
+
+	t, err := template.New("path").Option("missingkey=error").Parse(tpl)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot parse vault path template")
+	}
+
+	buf := bytes.Buffer{}
+	err = t.Execute(&buf, map[string]string{
+		"Name":    name,
+		"Account": account,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "cannot render vault path template")
+	}
+
+	path := strings.Trim(buf.String(), "/")
+	if len(path) == 0 {
+		return "", errors.New("empty vault path")
+	}
+
+	return path, nil
+}
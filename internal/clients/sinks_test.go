@@ -0,0 +1,157 @@
+package clients
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/vault"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/vault/vaulttest"
+)
+
+func TestVaultSink(t *testing.T) {
+	srv := vaulttest.NewServer("s3cr3t")
+	defer srv.Close()
+
+	ctx := context.Background()
+	cli, err := vault.NewClient(ctx, vault.ClientOptions{Address: srv.URL, Token: "s3cr3t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "ci", UID: "1234"}}
+	sink := &vaultSink{cli: cli, path: "krateo/argocd/ci", owner: owner}
+
+	opts := CreateSecretOpts{
+		Token:     "token",
+		TargetURL: "https://argocd.example.com",
+		Account:   "ci",
+		Template:  &endpointsv1alpha1.SecretTemplate{TokenKey: "ARGOCD_AUTH_TOKEN"},
+	}
+
+	if token, err := sink.Token(ctx); err != nil || token != "" {
+		t.Errorf("Token(...) on missing secret: want empty token and no error, got %q, %v", token, err)
+	}
+
+	if err := sink.Write(ctx, opts); err != nil {
+		t.Fatalf("Write(...): %v", err)
+	}
+
+	want := map[string]string{"ARGOCD_AUTH_TOKEN": "token", "target": "https://argocd.example.com"}
+	if diff := cmp.Diff(want, srv.Data("krateo/argocd/ci")); diff != "" {
+		t.Errorf("Write(...): -want, +got:\n%s", diff)
+	}
+
+	if token, err := sink.Token(ctx); err != nil || token != "token" {
+		t.Errorf("Token(...): want %q, got %q, %v", "token", token, err)
+	}
+
+	if ok, err := sink.IsUpToDate(ctx, opts); err != nil || !ok {
+		t.Errorf("IsUpToDate(...): want true, got %t, %v", ok, err)
+	}
+
+	opts.TargetURL = "https://argocd.example.org"
+	if ok, err := sink.IsUpToDate(ctx, opts); err != nil || ok {
+		t.Errorf("IsUpToDate(...) after target change: want false, got %t, %v", ok, err)
+	}
+
+	if err := sink.Delete(ctx); err != nil {
+		t.Fatalf("Delete(...): %v", err)
+	}
+	if got := srv.Data("krateo/argocd/ci"); got != nil {
+		t.Errorf("Delete(...): want no data, got %v", got)
+	}
+}
+
+func TestVaultSinkNotOwned(t *testing.T) {
+	srv := vaulttest.NewServer("s3cr3t")
+	defer srv.Close()
+
+	srv.SetData("krateo/argocd/ci", map[string]string{"password": "untouchable"}, nil)
+
+	ctx := context.Background()
+	cli, err := vault.NewClient(ctx, vault.ClientOptions{Address: srv.URL, Token: "s3cr3t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "ci", UID: "1234"}}
+	sink := &vaultSink{cli: cli, path: "krateo/argocd/ci", owner: owner}
+
+	if _, err := sink.Token(ctx); !IsSecretNotOwned(err) {
+		t.Errorf("Token(...): want not owned error, got %v", err)
+	}
+	if err := sink.Write(ctx, CreateSecretOpts{Token: "token", TargetURL: "https://argocd.example.com"}); !IsSecretNotOwned(err) {
+		t.Errorf("Write(...): want not owned error, got %v", err)
+	}
+	if err := sink.Delete(ctx); !IsSecretNotOwned(err) {
+		t.Errorf("Delete(...): want not owned error, got %v", err)
+	}
+
+	want := map[string]string{"password": "untouchable"}
+	if diff := cmp.Diff(want, srv.Data("krateo/argocd/ci")); diff != "" {
+		t.Errorf("foreign secret modified: -want, +got:\n%s", diff)
+	}
+}
+
+func TestVaultSinkPartialWrite(t *testing.T) {
+	ctx := context.Background()
+	owner := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "ci", UID: "1234"}}
+	opts := CreateSecretOpts{Token: "token", TargetURL: "https://argocd.example.com", Owner: owner}
+
+	for _, kind := range []string{"metadata", "data"} {
+		t.Run(kind, func(t *testing.T) {
+			srv := vaulttest.NewServer("s3cr3t")
+			defer srv.Close()
+
+			cli, err := vault.NewClient(ctx, vault.ClientOptions{Address: srv.URL, Token: "s3cr3t"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink := &vaultSink{cli: cli, path: "krateo/argocd/ci", owner: owner}
+
+			srv.FailWrites = kind
+			if err := sink.Write(ctx, opts); err == nil {
+				t.Fatalf("Write(...): want error with failing %s writes, got nil", kind)
+			}
+
+			// a failed write must not leave the path looking foreign
+			srv.FailWrites = ""
+			if err := sink.Write(ctx, opts); err != nil {
+				t.Fatalf("Write(...) after a failed %s write: %v", kind, err)
+			}
+			if token, err := sink.Token(ctx); err != nil || token != "token" {
+				t.Errorf("Token(...): want token, got %q, %v", token, err)
+			}
+		})
+	}
+}
+
+func TestRenderVaultPath(t *testing.T) {
+	cases := map[string]struct {
+		tpl     string
+		want    string
+		wantErr bool
+	}{
+		"Default":   {tpl: "", want: "krateo/argocd/ci"},
+		"Custom":    {tpl: "/teams/{{ .Account }}/{{ .Name }}/", want: "teams/deployer/ci"},
+		"Unknown":   {tpl: "{{ .Namespace }}", wantErr: true},
+		"Empty":     {tpl: "/", wantErr: true},
+		"Malformed": {tpl: "{{ .Name", wantErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := renderVaultPath(tc.tpl, "ci", "deployer")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("renderVaultPath(...): want error %t, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("renderVaultPath(...): want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
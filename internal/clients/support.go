@@ -2,76 +2,460 @@ package clients
 
 import (
 	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/accounts"
 )
 
 const (
-	tokenKey = "bearer"
+	tokenKey  = "bearer"
+	targetKey = "target"
+
+	// LabelManagedBy marks the secrets written by this provider.
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	// LabelEndpointName holds the name of the Endpoint owning a secret.
+	LabelEndpointName = "argocd.krateo.io/endpoint-name"
+	// LabelEndpointUID holds the UID of the Endpoint owning a secret.
+	LabelEndpointUID = "argocd.krateo.io/endpoint-uid"
+	// LabelEndpointNamespace holds the namespace of the NamespacedEndpoint
+	// owning a secret; unset for secrets owned by an Endpoint.
+	LabelEndpointNamespace = "argocd.krateo.io/endpoint-namespace"
+
+	// annotationTokenKey records the secret key holding the token, so that
+	// the token can still be found after the key has been renamed.
+	annotationTokenKey = "argocd.krateo.io/token-key"
+
+	// annotationAppliedMetadata records the keys of the labels and of the
+	// annotations last applied to the secret, so that the ones removed from
+	// the template are removed from the secret too.
+	annotationAppliedMetadata = "argocd.krateo.io/applied-metadata"
+
+	managedByValue = "provider-argocd-endpoint"
 )
 
+// defaultLabels are the labels used by the Krateo UI.
+var defaultLabels = map[string]string{
+	"app.kubernetes.io/created-by": "krateo",
+	"category":                     "delivery",
+	"group":                        "endpoint",
+	"icon":                         "fa-solid_fa-truck",
+	"type":                         "argocd",
+}
+
+var errSecretNotOwned = errors.New("secret is not owned by this endpoint")
+
+// IsSecretNotOwned returns true if the supplied error indicates that a
+// secret exists but has not been created by the endpoint referencing it.
+func IsSecretNotOwned(err error) bool {
+	return errors.Cause(err) == errSecretNotOwned
+}
+
+// IsSecretOwnedBy returns true if the supplied secret carries the ownership
+// marker of the supplied endpoint.
+func IsSecretOwnedBy(s *corev1.Secret, owner metav1.Object) bool {
+	if s == nil || owner == nil {
+		return false
+	}
+
+	lbl := s.GetLabels()
+	return lbl[LabelManagedBy] == managedByValue &&
+		lbl[LabelEndpointUID] == string(owner.GetUID())
+}
+
+// EndpointOf returns the name, and the namespace for a NamespacedEndpoint,
+// of the Endpoint owning the supplied object, if the object has been written
+// by this provider.
+func EndpointOf(o metav1.Object) (types.NamespacedName, bool) {
+	lbl := o.GetLabels()
+	if lbl[LabelManagedBy] != managedByValue {
+		return types.NamespacedName{}, false
+	}
+
+	name, ok := lbl[LabelEndpointName]
+	return types.NamespacedName{Name: name, Namespace: lbl[LabelEndpointNamespace]}, ok && len(name) > 0
+}
+
 type CreateSecretOpts struct {
 	Token     string
 	TargetURL string
+	Account   string
+	CACert    []byte
+	// TokenID and ExpiresAt are the claims of the token, used when they
+	// cannot be parsed from Token, e.g. because it is encrypted.
+	TokenID   string
+	ExpiresAt *time.Time
+	Insecure  bool
+	GRPCWeb   bool
 	SecretRef *xpv1.SecretReference
+	Owner     metav1.Object
+	Template  *endpointsv1alpha1.SecretTemplate
 }
 
-func CreateEndpointSecret(ctx context.Context, k client.Client, opts CreateSecretOpts) error {
+// ValidateSecretTemplate checks that the supplied secret template is consistent
+// and that all its Go templates can be rendered.
+func ValidateSecretTemplate(tpl *endpointsv1alpha1.SecretTemplate) error {
+	if tpl == nil {
+		return nil
+	}
+
+	tk, ak := secretKeys(tpl)
+	if tk == ak {
+		return errors.Errorf("token and target keys must differ (both are %q)", tk)
+	}
+
+	reserved := map[string]bool{tk: true, ak: true}
+	if tpl.Format == endpointsv1alpha1.SecretFormatArgoCDConfig {
+		if reserved[argocdConfigKey] {
+			return errors.Errorf("key %q is reserved for the argocd CLI config", argocdConfigKey)
+		}
+		reserved[argocdConfigKey] = true
+	}
+
+	for key := range tpl.Data {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return errors.Errorf("invalid template key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if reserved[key] {
+			return errors.Errorf("template key %q clashes with a key written by the provider", key)
+		}
+	}
+
+	return ValidateTemplates(tpl.Data)
+}
+
+// ValidateEncryptedSecretTemplate checks that the supplied secret template
+// can be used along with the token encryption: the argocd CLI config and
+// the templates would hold the encrypted token, which is useless to them.
+func ValidateEncryptedSecretTemplate(tpl *endpointsv1alpha1.SecretTemplate) error {
+	if tpl == nil {
+		return nil
+	}
+
+	if tpl.Format == endpointsv1alpha1.SecretFormatArgoCDConfig {
+		return errors.Errorf("format %s cannot be used along with encryption", tpl.Format)
+	}
+
+	if keys := TemplatesUsing(tpl.Data, "Token"); len(keys) > 0 {
+		return errors.Errorf("templates of keys %s use .Token, which cannot be used along with encryption", strings.Join(keys, ", "))
+	}
+
+	return nil
+}
+
+// NewEndpointSecret returns the endpoint secret described by the supplied options.
+func NewEndpointSecret(opts CreateSecretOpts) (*corev1.Secret, error) {
 	if opts.SecretRef == nil {
-		return errors.New("no endpoint secret referenced")
+		return nil, errors.New("no endpoint secret referenced")
+	}
+	if opts.Owner == nil {
+		return nil, errors.New("no endpoint secret owner specified")
 	}
 
+	tpl := opts.Template
+	if tpl == nil {
+		tpl = &endpointsv1alpha1.SecretTemplate{}
+	}
+
+	if err := ValidateSecretTemplate(tpl); err != nil {
+		return nil, err
+	}
+
+	data, err := RenderTemplates(tpl.Data, NewTemplateData(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	tk, ak := secretKeys(tpl)
+
 	s := &corev1.Secret{}
 	s.Name = opts.SecretRef.Name
 	s.Namespace = opts.SecretRef.Namespace
-	s.Labels = map[string]string{
-		"app.kubernetes.io/created-by": "krateo",
-		"category":                     "delivery",
-		"group":                        "endpoint",
-		"icon":                         "fa-solid_fa-truck",
-		"type":                         "argocd",
+
+	s.Labels = map[string]string{}
+	if !tpl.SkipDefaultLabels {
+		for k, v := range defaultLabels {
+			s.Labels[k] = v
+		}
+	}
+	for k, v := range tpl.Labels {
+		s.Labels[k] = v
 	}
-	s.StringData = map[string]string{
-		tokenKey: opts.Token,
-		"target": opts.TargetURL,
+	// ownership labels always win over user supplied ones
+	s.Labels[LabelManagedBy] = managedByValue
+	s.Labels[LabelEndpointName] = opts.Owner.GetName()
+	s.Labels[LabelEndpointUID] = string(opts.Owner.GetUID())
+	if ns := opts.Owner.GetNamespace(); len(ns) > 0 {
+		s.Labels[LabelEndpointNamespace] = ns
 	}
 
-	return k.Create(ctx, s)
+	s.Annotations = map[string]string{}
+	for k, v := range tpl.Annotations {
+		s.Annotations[k] = v
+	}
+	s.Annotations[annotationTokenKey] = tk
+	s.Annotations[annotationAppliedMetadata] = appliedMetadata(s.Labels, s.Annotations)
+
+	data[tk] = []byte(opts.Token)
+	data[ak] = []byte(opts.TargetURL)
+
+	if tpl.Format == endpointsv1alpha1.SecretFormatArgoCDConfig {
+		cfg, err := NewArgoCDConfig(ArgoCDConfigOpts{
+			ServerURL: opts.TargetURL,
+			Token:     opts.Token,
+			Insecure:  opts.Insecure,
+			GRPCWeb:   opts.GRPCWeb,
+		})
+		if err != nil {
+			return nil, err
+		}
+		data[argocdConfigKey] = cfg
+	}
+
+	s.Data = data
+
+	return s, nil
 }
 
-func GetEndpointSecret(ctx context.Context, k client.Client, ref *xpv1.SecretReference) (string, error) {
+// NewTemplateData returns the data model for rendering the secret templates.
+func NewTemplateData(opts CreateSecretOpts) TemplateData {
+	res := TemplateData{
+		Token:     opts.Token,
+		ServerURL: opts.TargetURL,
+		Account:   opts.Account,
+		CA:        string(opts.CACert),
+	}
+
+	claims, err := accounts.ParseTokenClaims(opts.Token)
+	if err != nil {
+		res.TokenID = opts.TokenID
+		if opts.ExpiresAt != nil {
+			res.ExpiresAt = opts.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		return res
+	}
+
+	res.TokenID = claims.ID
+	if exp := claims.ExpirationTime(); !exp.IsZero() {
+		res.ExpiresAt = exp.Format(time.RFC3339)
+	}
+
+	return res
+}
+
+// CreateEndpointSecret creates the endpoint secret, or updates it if it
+// already exists and is owned by the endpoint.
+func CreateEndpointSecret(ctx context.Context, k client.Client, opts CreateSecretOpts) error {
+	want, err := NewEndpointSecret(opts)
+	if err != nil {
+		return err
+	}
+
+	got := &corev1.Secret{}
+	err = k.Get(ctx, types.NamespacedName{Namespace: want.Namespace, Name: want.Name}, got)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return k.Create(ctx, want)
+		}
+		return errors.Wrapf(err, "cannot get %s secret in namespace %s", want.Name, want.Namespace)
+	}
+
+	if !IsSecretOwnedBy(got, opts.Owner) {
+		return errors.Wrapf(errSecretNotOwned, "refusing to overwrite %s secret in namespace %s", want.Name, want.Namespace)
+	}
+
+	applied := parseAppliedMetadata(got.GetAnnotations()[annotationAppliedMetadata])
+	got.Labels = mergeMetadata(got.Labels, want.Labels, applied["labels"])
+	got.Annotations = mergeMetadata(got.Annotations, want.Annotations, applied["annotations"])
+	got.Data = want.Data
+
+	return k.Update(ctx, got)
+}
+
+// GetEndpointSecret returns the referenced secret, or nil if it does not exist.
+// An error satisfying IsSecretNotOwned is returned if the secret exists
+// but has not been created by the supplied owner.
+func GetEndpointSecret(ctx context.Context, k client.Client, ref *xpv1.SecretReference, owner metav1.Object) (*corev1.Secret, error) {
 	if ref == nil {
-		return "", errors.New("no credentials secret referenced")
+		return nil, errors.New("no credentials secret referenced")
 	}
 
 	s := &corev1.Secret{}
 	err := k.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			return "", nil
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "cannot get %s secret in namespace %s", ref.Name, ref.Namespace)
+	}
+
+	if !IsSecretOwnedBy(s, owner) {
+		return nil, errors.Wrapf(errSecretNotOwned, "%s secret in namespace %s already exists", ref.Name, ref.Namespace)
+	}
+
+	return s, nil
+}
+
+// ListEndpointSecrets returns all the secrets, in any namespace, owned by the supplied endpoint.
+func ListEndpointSecrets(ctx context.Context, k client.Client, owner metav1.Object) ([]corev1.Secret, error) {
+	list := &corev1.SecretList{}
+	err := k.List(ctx, list, client.MatchingLabels{
+		LabelManagedBy:   managedByValue,
+		LabelEndpointUID: string(owner.GetUID()),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list endpoint secrets")
+	}
+
+	return list.Items, nil
+}
+
+// TokenFromSecret returns the token stored in an endpoint secret.
+func TokenFromSecret(s *corev1.Secret) string {
+	if s == nil {
+		return ""
+	}
+
+	key := tokenKey
+	if k := s.GetAnnotations()[annotationTokenKey]; len(k) > 0 {
+		key = k
+	}
+
+	return string(s.Data[key])
+}
+
+// IsEndpointSecretUpToDate returns true if the current secret holds the
+// same data, labels and annotations of the desired one, and none of the
+// labels and annotations previously applied but no longer desired.
+// Labels and annotations added by others are ignored.
+func IsEndpointSecretUpToDate(current, desired *corev1.Secret) bool {
+	if current == nil || desired == nil {
+		return false
+	}
+
+	if !cmp.Equal(current.Data, desired.Data, cmpopts.EquateEmpty()) {
+		return false
+	}
+
+	// containsAll compares the applied metadata records too: they differ
+	// as soon as a label or an annotation is added to or removed from the
+	// template.
+	return containsAll(current.GetLabels(), desired.GetLabels()) &&
+		containsAll(current.GetAnnotations(), desired.GetAnnotations())
+}
+
+// appliedMetadata returns the record of the keys of the supplied labels
+// and annotations.
+func appliedMetadata(labels, annotations map[string]string) string {
+	keys := func(m map[string]string) []string {
+		res := make([]string, 0, len(m))
+		for k := range m {
+			if k != annotationAppliedMetadata {
+				res = append(res, k)
+			}
 		}
-		return "", errors.Wrapf(err, "cannot get %s secret in namespace %s", ref.Name, ref.Namespace)
+		sort.Strings(res)
+		return res
+	}
+
+	b, _ := json.Marshal(map[string][]string{
+		"labels":      keys(labels),
+		"annotations": keys(annotations),
+	})
+	return string(b)
+}
+
+// secretKeys returns the names of the token and target keys.
+func secretKeys(tpl *endpointsv1alpha1.SecretTemplate) (string, string) {
+	tk, ak := tokenKey, targetKey
+	if tpl == nil {
+		return tk, ak
+	}
+	if len(tpl.TokenKey) > 0 {
+		tk = tpl.TokenKey
+	}
+	if len(tpl.TargetKey) > 0 {
+		ak = tpl.TargetKey
+	}
+	return tk, ak
+}
+
+// parseAppliedMetadata returns the keys recorded by appliedMetadata.
+func parseAppliedMetadata(s string) map[string][]string {
+	res := map[string][]string{}
+	if len(s) > 0 {
+		_ = json.Unmarshal([]byte(s), &res)
 	}
+	return res
+}
 
-	return string(s.Data[tokenKey]), nil
+// mergeMetadata returns the current labels, or annotations, without the
+// previously applied keys and with the desired ones.
+func mergeMetadata(current, desired map[string]string, applied []string) map[string]string {
+	res := make(map[string]string, len(current)+len(desired))
+	for k, v := range current {
+		res[k] = v
+	}
+	for _, k := range applied {
+		delete(res, k)
+	}
+	for k, v := range desired {
+		res[k] = v
+	}
+	return res
 }
 
-func DeleteEndpointSecret(ctx context.Context, k client.Client, ref *xpv1.SecretReference) error {
+// containsAll returns true if all the entries of want are in got.
+func containsAll(got, want map[string]string) bool {
+	for k, v := range want {
+		if x, ok := got[k]; !ok || x != v {
+			return false
+		}
+	}
+	return true
+}
+
+// DeleteEndpointSecret deletes the referenced secret if it has been created
+// by the supplied owner. Missing secrets are ignored.
+func DeleteEndpointSecret(ctx context.Context, k client.Client, ref *xpv1.SecretReference, owner metav1.Object) error {
 	if ref == nil {
 		return errors.New("no endpoint secret referenced")
 	}
 
 	s := &corev1.Secret{}
-	s.Name = ref.Name
-	s.Namespace = ref.Namespace
+	err := k.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "cannot get %s secret in namespace %s", ref.Name, ref.Namespace)
+	}
+
+	if !IsSecretOwnedBy(s, owner) {
+		return errors.Wrapf(errSecretNotOwned, "refusing to delete %s secret in namespace %s", ref.Name, ref.Namespace)
+	}
+
+	err = k.Delete(ctx, s, client.Preconditions{UID: &s.UID})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
 
-	return k.Delete(ctx, s)
+	return err
 }
 
 /*
@@ -0,0 +1,516 @@
+package clients
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+)
+
+func newSecretOpts(owner metav1.Object) CreateSecretOpts {
+	return CreateSecretOpts{
+		Token:     "token",
+		TargetURL: "https://argocd.example.com",
+		Account:   "ci",
+		SecretRef: &xpv1.SecretReference{Name: "ci-endpoint", Namespace: "default"},
+		Owner:     owner,
+	}
+}
+
+func newForeignSecret() *corev1.Secret {
+	s := &corev1.Secret{}
+	s.Name = "ci-endpoint"
+	s.Namespace = "default"
+	s.Data = map[string][]byte{"bearer": []byte("foreign")}
+	return s
+}
+
+func TestNewEndpointSecret(t *testing.T) {
+	owner := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "ci", UID: "1234"}}
+
+	cases := map[string]struct {
+		opts     func(CreateSecretOpts) CreateSecretOpts
+		wantData map[string]string
+		wantErr  bool
+	}{
+		"Default": {
+			opts:     func(o CreateSecretOpts) CreateSecretOpts { return o },
+			wantData: map[string]string{"bearer": "token", "target": "https://argocd.example.com"},
+		},
+		"CustomKeys": {
+			opts: func(o CreateSecretOpts) CreateSecretOpts {
+				o.Template = &endpointsv1alpha1.SecretTemplate{
+					TokenKey:  "ARGOCD_AUTH_TOKEN",
+					TargetKey: "ARGOCD_SERVER",
+					Data:      map[string]string{"account": "{{ .Account }}"},
+				}
+				return o
+			},
+			wantData: map[string]string{"ARGOCD_AUTH_TOKEN": "token", "ARGOCD_SERVER": "https://argocd.example.com", "account": "ci"},
+		},
+		"ClashingKeys": {
+			opts: func(o CreateSecretOpts) CreateSecretOpts {
+				o.Template = &endpointsv1alpha1.SecretTemplate{TokenKey: "target"}
+				return o
+			},
+			wantErr: true,
+		},
+		"NoSecretRef": {
+			opts: func(o CreateSecretOpts) CreateSecretOpts {
+				o.SecretRef = nil
+				return o
+			},
+			wantErr: true,
+		},
+		"NoOwner": {
+			opts: func(o CreateSecretOpts) CreateSecretOpts {
+				o.Owner = nil
+				return o
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s, err := NewEndpointSecret(tc.opts(newSecretOpts(owner)))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("NewEndpointSecret(...): want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewEndpointSecret(...): %v", err)
+			}
+
+			got := map[string]string{}
+			for k, v := range s.Data {
+				got[k] = string(v)
+			}
+			if diff := cmp.Diff(tc.wantData, got); diff != "" {
+				t.Errorf("NewEndpointSecret(...): -want data, +got data:\n%s", diff)
+			}
+
+			if !IsSecretOwnedBy(s, owner) {
+				t.Errorf("NewEndpointSecret(...): want secret owned by %s", owner.Name)
+			}
+			if key, ok := EndpointOf(s); !ok || key.Name != owner.Name || len(key.Namespace) > 0 {
+				t.Errorf("EndpointOf(...): want %q, got %q", owner.Name, key)
+			}
+		})
+	}
+}
+
+func TestCreateEndpointSecret(t *testing.T) {
+	owner := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "ci", UID: "1234"}}
+
+	owned, err := NewEndpointSecret(newSecretOpts(owner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	owned.Data["bearer"] = []byte("old")
+
+	opts := newSecretOpts(owner)
+	opts.Template = &endpointsv1alpha1.SecretTemplate{Labels: map[string]string{"team": "platform"}}
+	templated, err := NewEndpointSecret(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templated.Labels["backup"] = "true"
+
+	cases := map[string]struct {
+		objs       []client.Object
+		wantToken  string
+		wantLabels map[string]string
+		wantErr    func(error) bool
+	}{
+		"Create": {
+			wantToken: "token",
+		},
+		"UpdateOwned": {
+			objs:      []client.Object{owned},
+			wantToken: "token",
+		},
+		"RemoveTemplateLabel": {
+			objs:       []client.Object{templated},
+			wantToken:  "token",
+			wantLabels: map[string]string{"team": "", "backup": "true"},
+		},
+		"NotOwned": {
+			objs:      []client.Object{newForeignSecret()},
+			wantToken: "foreign",
+			wantErr:   IsSecretNotOwned,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube := newFakeClient(t, tc.objs...)
+
+			err := CreateEndpointSecret(context.Background(), kube, newSecretOpts(owner))
+			if tc.wantErr != nil {
+				if !tc.wantErr(err) {
+					t.Errorf("CreateEndpointSecret(...): unexpected error %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("CreateEndpointSecret(...): %v", err)
+			}
+
+			got := &corev1.Secret{}
+			if err := kube.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "ci-endpoint"}, got); err != nil {
+				t.Fatal(err)
+			}
+			if token := string(got.Data["bearer"]); token != tc.wantToken {
+				t.Errorf("CreateEndpointSecret(...): want token %q, got %q", tc.wantToken, token)
+			}
+			for k, v := range tc.wantLabels {
+				if got.Labels[k] != v {
+					t.Errorf("CreateEndpointSecret(...): want label %s=%q, got %q", k, v, got.Labels[k])
+				}
+			}
+		})
+	}
+}
+
+func TestGetEndpointSecret(t *testing.T) {
+	owner := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "ci", UID: "1234"}}
+	other := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "ci", UID: "5678"}}
+
+	owned, err := NewEndpointSecret(newSecretOpts(owner))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		objs      []client.Object
+		owner     metav1.Object
+		ref       *xpv1.SecretReference
+		wantToken string
+		wantErr   func(error) bool
+	}{
+		"Missing": {
+			owner: owner,
+			ref:   newSecretOpts(owner).SecretRef,
+		},
+		"Owned": {
+			objs:      []client.Object{owned},
+			owner:     owner,
+			ref:       newSecretOpts(owner).SecretRef,
+			wantToken: "token",
+		},
+		"OwnedByAnotherEndpoint": {
+			objs:    []client.Object{owned},
+			owner:   other,
+			ref:     newSecretOpts(owner).SecretRef,
+			wantErr: IsSecretNotOwned,
+		},
+		"NotOwned": {
+			objs:    []client.Object{newForeignSecret()},
+			owner:   owner,
+			ref:     newSecretOpts(owner).SecretRef,
+			wantErr: IsSecretNotOwned,
+		},
+		"NoSecretRef": {
+			owner:   owner,
+			wantErr: func(err error) bool { return err != nil },
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube := newFakeClient(t, tc.objs...)
+
+			s, err := GetEndpointSecret(context.Background(), kube, tc.ref, tc.owner)
+			if tc.wantErr != nil {
+				if !tc.wantErr(err) {
+					t.Errorf("GetEndpointSecret(...): unexpected error %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetEndpointSecret(...): %v", err)
+			}
+
+			if token := TokenFromSecret(s); token != tc.wantToken {
+				t.Errorf("TokenFromSecret(...): want %q, got %q", tc.wantToken, token)
+			}
+		})
+	}
+}
+
+func TestDeleteEndpointSecret(t *testing.T) {
+	owner := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "ci", UID: "1234"}}
+
+	owned, err := NewEndpointSecret(newSecretOpts(owner))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		objs        []client.Object
+		wantErr     func(error) bool
+		wantDeleted bool
+	}{
+		"Missing": {
+			wantDeleted: true,
+		},
+		"Owned": {
+			objs:        []client.Object{owned},
+			wantDeleted: true,
+		},
+		"NotOwned": {
+			objs:    []client.Object{newForeignSecret()},
+			wantErr: IsSecretNotOwned,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube := newFakeClient(t, tc.objs...)
+
+			err := DeleteEndpointSecret(context.Background(), kube, newSecretOpts(owner).SecretRef, owner)
+			if tc.wantErr != nil {
+				if !tc.wantErr(err) {
+					t.Errorf("DeleteEndpointSecret(...): unexpected error %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("DeleteEndpointSecret(...): %v", err)
+			}
+
+			err = kube.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "ci-endpoint"}, &corev1.Secret{})
+			if deleted := apierrors.IsNotFound(err); deleted != tc.wantDeleted {
+				t.Errorf("DeleteEndpointSecret(...): want deleted %t, got %t", tc.wantDeleted, deleted)
+			}
+		})
+	}
+}
+
+func TestIsEndpointSecretUpToDate(t *testing.T) {
+	owner := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "ci", UID: "1234"}}
+
+	want, err := NewEndpointSecret(newSecretOpts(owner))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		current func() *corev1.Secret
+		desired func() *corev1.Secret
+		want    bool
+	}{
+		"UpToDate": {
+			current: func() *corev1.Secret { return want.DeepCopy() },
+			want:    true,
+		},
+		"ExtraLabels": {
+			current: func() *corev1.Secret {
+				s := want.DeepCopy()
+				s.Labels["team"] = "platform"
+				return s
+			},
+			want: true,
+		},
+		"ChangedData": {
+			current: func() *corev1.Secret {
+				s := want.DeepCopy()
+				s.Data["bearer"] = []byte("changed")
+				return s
+			},
+		},
+		"MissingAnnotation": {
+			current: func() *corev1.Secret {
+				s := want.DeepCopy()
+				s.Annotations = nil
+				return s
+			},
+		},
+		"Missing": {
+			current: func() *corev1.Secret { return nil },
+		},
+		"RemovedTemplateLabel": {
+			current: func() *corev1.Secret {
+				opts := newSecretOpts(owner)
+				opts.Template = &endpointsv1alpha1.SecretTemplate{Labels: map[string]string{"team": "platform"}}
+				s, _ := NewEndpointSecret(opts)
+				return s
+			},
+		},
+		"RemovedTemplateAnnotation": {
+			current: func() *corev1.Secret {
+				opts := newSecretOpts(owner)
+				opts.Template = &endpointsv1alpha1.SecretTemplate{Annotations: map[string]string{"owner": "platform"}}
+				s, _ := NewEndpointSecret(opts)
+				return s
+			},
+		},
+		"SkipDefaultLabels": {
+			current: func() *corev1.Secret { return want.DeepCopy() },
+			desired: func() *corev1.Secret {
+				opts := newSecretOpts(owner)
+				opts.Template = &endpointsv1alpha1.SecretTemplate{SkipDefaultLabels: true}
+				s, _ := NewEndpointSecret(opts)
+				return s
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			desired := want
+			if tc.desired != nil {
+				desired = tc.desired()
+			}
+			if got := IsEndpointSecretUpToDate(tc.current(), desired); got != tc.want {
+				t.Errorf("IsEndpointSecretUpToDate(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTokenFromSecret(t *testing.T) {
+	cases := map[string]struct {
+		secret *corev1.Secret
+		want   string
+	}{
+		"Nil": {},
+		"DefaultKey": {
+			secret: &corev1.Secret{Data: map[string][]byte{"bearer": []byte("token")}},
+			want:   "token",
+		},
+		"AnnotatedKey": {
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{annotationTokenKey: "ARGOCD_AUTH_TOKEN"}},
+				Data:       map[string][]byte{"bearer": []byte("stale"), "ARGOCD_AUTH_TOKEN": []byte("token")},
+			},
+			want: "token",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := TokenFromSecret(tc.secret); got != tc.want {
+				t.Errorf("TokenFromSecret(...): want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateSecretTemplate(t *testing.T) {
+	cases := map[string]struct {
+		tpl     *endpointsv1alpha1.SecretTemplate
+		wantErr bool
+	}{
+		"Nil": {},
+		"Valid": {
+			tpl: &endpointsv1alpha1.SecretTemplate{Data: map[string]string{"account": "{{ .Account }}"}},
+		},
+		"SameKeys": {
+			tpl:     &endpointsv1alpha1.SecretTemplate{TokenKey: "key", TargetKey: "key"},
+			wantErr: true,
+		},
+		"ReservedKey": {
+			tpl:     &endpointsv1alpha1.SecretTemplate{Data: map[string]string{"bearer": "x"}},
+			wantErr: true,
+		},
+		"ReservedConfigKey": {
+			tpl: &endpointsv1alpha1.SecretTemplate{
+				Format: endpointsv1alpha1.SecretFormatArgoCDConfig,
+				Data:   map[string]string{argocdConfigKey: "x"},
+			},
+			wantErr: true,
+		},
+		"InvalidTemplate": {
+			tpl:     &endpointsv1alpha1.SecretTemplate{Data: map[string]string{"account": "{{ .Account "}},
+			wantErr: true,
+		},
+		"DottedKey": {
+			tpl: &endpointsv1alpha1.SecretTemplate{Data: map[string]string{"config.json": "{}"}},
+		},
+		"KeyWithSlash": {
+			tpl:     &endpointsv1alpha1.SecretTemplate{Data: map[string]string{"a/b": "x"}},
+			wantErr: true,
+		},
+		"KeyWithSpace": {
+			tpl:     &endpointsv1alpha1.SecretTemplate{Data: map[string]string{"server url": "x"}},
+			wantErr: true,
+		},
+		"EmptyKey": {
+			tpl:     &endpointsv1alpha1.SecretTemplate{Data: map[string]string{"": "x"}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateSecretTemplate(tc.tpl)
+			if got := err != nil; got != tc.wantErr {
+				t.Errorf("ValidateSecretTemplate(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateEncryptedSecretTemplate(t *testing.T) {
+	cases := map[string]struct {
+		tpl     *endpointsv1alpha1.SecretTemplate
+		wantErr bool
+	}{
+		"Nil": {},
+		"NoToken": {
+			tpl: &endpointsv1alpha1.SecretTemplate{Data: map[string]string{
+				"expires": "{{ .ExpiresAt }}",
+				"id":      "{{ with .TokenID }}{{ . }}{{ end }}",
+			}},
+		},
+		"ArgoCDConfig": {
+			tpl:     &endpointsv1alpha1.SecretTemplate{Format: endpointsv1alpha1.SecretFormatArgoCDConfig},
+			wantErr: true,
+		},
+		"Token": {
+			tpl:     &endpointsv1alpha1.SecretTemplate{Data: map[string]string{"header": "Bearer {{ .Token }}"}},
+			wantErr: true,
+		},
+		"TokenInPipeline": {
+			tpl:     &endpointsv1alpha1.SecretTemplate{Data: map[string]string{"b64": "{{ .Token | b64enc }}"}},
+			wantErr: true,
+		},
+		"TokenInBranch": {
+			tpl:     &endpointsv1alpha1.SecretTemplate{Data: map[string]string{"json": "{{ if .Account }}{{ quote $.Token }}{{ end }}"}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateEncryptedSecretTemplate(tc.tpl)
+			if got := err != nil; got != tc.wantErr {
+				t.Errorf("ValidateEncryptedSecretTemplate(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewTemplateDataEncrypted(t *testing.T) {
+	exp := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	got := NewTemplateData(CreateSecretOpts{
+		Token:     "encrypted-envelope",
+		TokenID:   "1234",
+		ExpiresAt: &exp,
+	})
+
+	if got.TokenID != "1234" || got.ExpiresAt != "2030-01-02T03:04:05Z" {
+		t.Errorf("NewTemplateData(...): want the supplied claims, got id %q, expiresAt %q", got.TokenID, got.ExpiresAt)
+	}
+}
@@ -0,0 +1,328 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMountPath = "secret"
+	defaultAuthMount = "kubernetes"
+	defaultTimeout   = 30 * time.Second
+
+	tokenHeader     = "X-Vault-Token"
+	namespaceHeader = "X-Vault-Namespace"
+)
+
+// ErrNotFound is returned when a secret does not exist.
+var ErrNotFound = errors.New("vault secret not found")
+
+// ClientOptions hold address, auth and TLS settings for the Vault client.
+type ClientOptions struct {
+	// Address of the Vault server.
+	Address string
+	// Namespace is the Vault Enterprise namespace; optional.
+	Namespace string
+	// MountPath of the KV v2 secrets engine. (Default: secret)
+	MountPath string
+	// Token used to authenticate; if empty the Kubernetes auth method is used.
+	Token string
+	// KubernetesAuthMount is the mount path of the Kubernetes auth method. (Default: kubernetes)
+	KubernetesAuthMount string
+	// KubernetesAuthRole is the role used with the Kubernetes auth method.
+	KubernetesAuthRole string
+	// KubernetesAuthJWT is the service account token used with the Kubernetes auth method.
+	KubernetesAuthJWT string
+	// CACert PEM encoded CA certificate of the Vault server.
+	CACert []byte
+	// Timeout of each request; 30s if zero. Ignored with HTTPClient.
+	Timeout time.Duration
+	// HTTPClient optional HTTP client.
+	HTTPClient *http.Client
+}
+
+// Client is a minimal Vault KV v2 client.
+type Client struct {
+	address    string
+	namespace  string
+	mountPath  string
+	token      string
+	httpClient *http.Client
+
+	// login identifies the cached login token in use, if any.
+	login *loginKey
+}
+
+// loginKey identifies a Kubernetes auth login.
+type loginKey struct {
+	address, namespace, mount, role, jwt string
+}
+
+// loginToken is a Vault token obtained by a login.
+type loginToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// loginTokens caches the tokens obtained by the Kubernetes auth logins, so
+// that a new token, and a new lease, is not issued for every reconcile.
+var loginTokens = struct {
+	sync.Mutex
+	m map[loginKey]loginToken
+}{m: map[loginKey]loginToken{}}
+
+// NewClient returns a new Vault client; it logs in with the Kubernetes auth
+// method when no token is supplied.
+func NewClient(ctx context.Context, opts ClientOptions) (*Client, error) {
+	if len(opts.Address) == 0 {
+		return nil, errors.New("unspecified vault address")
+	}
+
+	res := &Client{
+		address:    strings.TrimSuffix(opts.Address, "/"),
+		namespace:  opts.Namespace,
+		mountPath:  strings.Trim(opts.MountPath, "/"),
+		token:      opts.Token,
+		httpClient: opts.HTTPClient,
+	}
+	if len(res.mountPath) == 0 {
+		res.mountPath = defaultMountPath
+	}
+
+	if res.httpClient == nil {
+		tlsConfig := &tls.Config{}
+		if len(opts.CACert) > 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(opts.CACert) {
+				return nil, errors.New("invalid CA certificate for vault")
+			}
+			tlsConfig.RootCAs = pool
+		}
+		res.httpClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+			Timeout:   defaultTimeout,
+		}
+		if opts.Timeout > 0 {
+			res.httpClient.Timeout = opts.Timeout
+		}
+	}
+
+	if len(res.token) > 0 {
+		return res, nil
+	}
+
+	if len(opts.KubernetesAuthRole) == 0 {
+		return nil, errors.New("either a vault token or a kubernetes auth role is required")
+	}
+
+	mount := strings.Trim(opts.KubernetesAuthMount, "/")
+	if len(mount) == 0 {
+		mount = defaultAuthMount
+	}
+
+	key := loginKey{
+		address:   res.address,
+		namespace: res.namespace,
+		mount:     mount,
+		role:      opts.KubernetesAuthRole,
+		jwt:       opts.KubernetesAuthJWT,
+	}
+
+	// the lock is held during the login, so that concurrent reconciles
+	// share a single login; res.login is only set afterwards, as do takes
+	// the lock too on permission errors.
+	loginTokens.Lock()
+	defer loginTokens.Unlock()
+
+	now := time.Now()
+	if tok, ok := loginTokens.m[key]; ok && (tok.expiresAt.IsZero() || now.Before(tok.expiresAt)) {
+		res.token, res.login = tok.token, &key
+		return res, nil
+	}
+
+	tok, err := res.kubernetesLogin(ctx, key)
+	if err != nil {
+		return res, err
+	}
+	res.token, res.login = tok.token, &key
+
+	for k, el := range loginTokens.m {
+		if !el.expiresAt.IsZero() && now.After(el.expiresAt) {
+			delete(loginTokens.m, k)
+		}
+	}
+	loginTokens.m[key] = tok
+
+	return res, nil
+}
+
+// Read returns the data of the latest version of the secret at the supplied path.
+func (c *Client) Read(ctx context.Context, path string) (map[string]string, error) {
+	var res struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+
+	err := c.do(ctx, http.MethodGet, c.url("data", path), nil, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data.Data, nil
+}
+
+// Write stores a new version of the secret at the supplied path.
+func (c *Client) Write(ctx context.Context, path string, data map[string]string) error {
+	body := map[string]interface{}{
+		"data": data,
+	}
+	return c.do(ctx, http.MethodPost, c.url("data", path), body, nil)
+}
+
+// ReadMetadata returns the custom metadata of the secret at the supplied path.
+func (c *Client) ReadMetadata(ctx context.Context, path string) (map[string]string, error) {
+	var res struct {
+		Data struct {
+			CustomMetadata map[string]string `json:"custom_metadata"`
+		} `json:"data"`
+	}
+
+	err := c.do(ctx, http.MethodGet, c.url("metadata", path), nil, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data.CustomMetadata, nil
+}
+
+// WriteMetadata sets the custom metadata of the secret at the supplied path.
+func (c *Client) WriteMetadata(ctx context.Context, path string, meta map[string]string) error {
+	body := map[string]interface{}{
+		"custom_metadata": meta,
+	}
+	return c.do(ctx, http.MethodPost, c.url("metadata", path), body, nil)
+}
+
+// Delete permanently deletes all the versions and the metadata of the secret
+// at the supplied path.
+func (c *Client) Delete(ctx context.Context, path string) error {
+	err := c.do(ctx, http.MethodDelete, c.url("metadata", path), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// kubernetesLogin exchanges the service account token for a Vault token;
+// the token is cached for 80% of its lease duration.
+func (c *Client) kubernetesLogin(ctx context.Context, key loginKey) (loginToken, error) {
+	var res struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int64  `json:"lease_duration"`
+		} `json:"auth"`
+	}
+
+	body := map[string]string{
+		"role": key.role,
+		"jwt":  key.jwt,
+	}
+
+	url := fmt.Sprintf("%s/v1/auth/%s/login", c.address, key.mount)
+	if err := c.do(ctx, http.MethodPost, url, body, &res); err != nil {
+		return loginToken{}, fmt.Errorf("vault kubernetes login failed: %w", err)
+	}
+
+	if len(res.Auth.ClientToken) == 0 {
+		return loginToken{}, errors.New("vault kubernetes login returned no token")
+	}
+
+	tok := loginToken{token: res.Auth.ClientToken}
+	if ttl := res.Auth.LeaseDuration; ttl > 0 {
+		tok.expiresAt = time.Now().Add(time.Duration(ttl) * time.Second * 8 / 10)
+	}
+
+	return tok, nil
+}
+
+// forgetLogin drops the cached login token, e.g. once it has been revoked.
+func (c *Client) forgetLogin() {
+	if c.login == nil {
+		return
+	}
+
+	loginTokens.Lock()
+	defer loginTokens.Unlock()
+
+	if tok, ok := loginTokens.m[*c.login]; ok && tok.token == c.token {
+		delete(loginTokens.m, *c.login)
+	}
+}
+
+func (c *Client) url(kind, path string) string {
+	return fmt.Sprintf("%s/v1/%s/%s/%s", c.address, c.mountPath, kind, strings.Trim(path, "/"))
+}
+
+func (c *Client) do(ctx context.Context, method, url string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		bin, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(bin)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(c.token) > 0 {
+		req.Header.Set(tokenHeader, c.token)
+	}
+	if len(c.namespace) > 0 {
+		req.Header.Set(namespaceHeader, c.namespace)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	bin, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode == http.StatusForbidden {
+		c.forgetLogin()
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("vault request %s %s failed: %s: %s", method, req.URL.Path, res.Status, strings.TrimSpace(string(bin)))
+	}
+
+	if out == nil || len(bin) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(bin, out)
+}
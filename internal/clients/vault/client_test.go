@@ -0,0 +1,185 @@
+package vault_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/vault"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/vault/vaulttest"
+)
+
+func TestNewClient(t *testing.T) {
+	srv := vaulttest.NewServer("s3cr3t")
+	defer srv.Close()
+	srv.Roles["provider"] = "jwt"
+
+	cases := map[string]struct {
+		reason  string
+		opts    vault.ClientOptions
+		wantErr bool
+	}{
+		"NoAddress": {
+			reason:  "An address is required.",
+			opts:    vault.ClientOptions{Token: "s3cr3t"},
+			wantErr: true,
+		},
+		"Token": {
+			reason: "A static token does not need a login.",
+			opts:   vault.ClientOptions{Address: srv.URL, Token: "s3cr3t"},
+		},
+		"NoAuth": {
+			reason:  "Either a token or a kubernetes role is required.",
+			opts:    vault.ClientOptions{Address: srv.URL},
+			wantErr: true,
+		},
+		"KubernetesLogin": {
+			reason: "The Kubernetes auth method exchanges the service account token for a Vault token.",
+			opts:   vault.ClientOptions{Address: srv.URL, KubernetesAuthRole: "provider", KubernetesAuthJWT: "jwt"},
+		},
+		"KubernetesLoginDenied": {
+			reason:  "A rejected login is an error.",
+			opts:    vault.ClientOptions{Address: srv.URL, KubernetesAuthRole: "provider", KubernetesAuthJWT: "wrong"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := vault.NewClient(context.Background(), tc.opts)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("\n%s\nNewClient(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestReadWriteDelete(t *testing.T) {
+	srv := vaulttest.NewServer("s3cr3t")
+	defer srv.Close()
+
+	ctx := context.Background()
+	cli, err := vault.NewClient(ctx, vault.ClientOptions{Address: srv.URL + "/", Token: "s3cr3t", Namespace: "team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cli.Read(ctx, "krateo/argocd/missing"); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("Read(...): want ErrNotFound, got %v", err)
+	}
+
+	data := map[string]string{"bearer": "token", "target": "https://argocd.example.com"}
+	if err := cli.Write(ctx, "/krateo/argocd/endpoint/", data); err != nil {
+		t.Fatalf("Write(...): %v", err)
+	}
+	if diff := cmp.Diff(data, srv.Data("krateo/argocd/endpoint")); diff != "" {
+		t.Errorf("Write(...): -want, +got:\n%s", diff)
+	}
+
+	got, err := cli.Read(ctx, "krateo/argocd/endpoint")
+	if err != nil {
+		t.Fatalf("Read(...): %v", err)
+	}
+	if diff := cmp.Diff(data, got); diff != "" {
+		t.Errorf("Read(...): -want, +got:\n%s", diff)
+	}
+
+	meta := map[string]string{"managed-by": "provider-argocd-endpoint"}
+	if err := cli.WriteMetadata(ctx, "krateo/argocd/endpoint", meta); err != nil {
+		t.Fatalf("WriteMetadata(...): %v", err)
+	}
+	gotMeta, err := cli.ReadMetadata(ctx, "krateo/argocd/endpoint")
+	if err != nil {
+		t.Fatalf("ReadMetadata(...): %v", err)
+	}
+	if diff := cmp.Diff(meta, gotMeta); diff != "" {
+		t.Errorf("ReadMetadata(...): -want, +got:\n%s", diff)
+	}
+
+	if err := cli.Delete(ctx, "krateo/argocd/endpoint"); err != nil {
+		t.Fatalf("Delete(...): %v", err)
+	}
+	if _, err := cli.Read(ctx, "krateo/argocd/endpoint"); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("Read(...) after Delete(...): want ErrNotFound, got %v", err)
+	}
+
+	for _, ns := range srv.Namespaces() {
+		if ns != "team-a" {
+			t.Errorf("want X-Vault-Namespace team-a, got %q", ns)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	srv := vaulttest.NewServer("s3cr3t")
+	defer srv.Close()
+	srv.Delay = time.Second
+
+	ctx := context.Background()
+	cli, err := vault.NewClient(ctx, vault.ClientOptions{Address: srv.URL, Token: "s3cr3t", Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cli.Read(ctx, "krateo/argocd/endpoint"); err == nil || !strings.Contains(err.Error(), "Client.Timeout exceeded") {
+		t.Errorf("Read(...): want timeout error, got %v", err)
+	}
+}
+
+func TestPermissionDenied(t *testing.T) {
+	srv := vaulttest.NewServer("s3cr3t")
+	defer srv.Close()
+
+	ctx := context.Background()
+	cli, err := vault.NewClient(ctx, vault.ClientOptions{Address: srv.URL, Token: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cli.Write(ctx, "krateo/argocd/endpoint", map[string]string{"k": "v"}); err == nil {
+		t.Errorf("Write(...): want error with wrong token, got nil")
+	}
+}
+
+func TestKubernetesLoginCached(t *testing.T) {
+	srv := vaulttest.NewServer("s3cr3t")
+	defer srv.Close()
+	srv.Roles["provider"] = "jwt"
+	srv.LeaseDuration = 3600
+
+	ctx := context.Background()
+	opts := vault.ClientOptions{Address: srv.URL, KubernetesAuthRole: "provider", KubernetesAuthJWT: "jwt"}
+
+	for i := 0; i < 3; i++ {
+		cli, err := vault.NewClient(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cli.Write(ctx, "krateo/argocd/endpoint", map[string]string{"k": "v"}); err != nil {
+			t.Fatalf("Write(...): %v", err)
+		}
+	}
+	if got := srv.Logins(); got != 1 {
+		t.Errorf("NewClient(...): want 1 login within the lease, got %d", got)
+	}
+
+	// a revoked token is dropped and a new login happens
+	srv.Token = "rotated"
+	cli, err := vault.NewClient(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Write(ctx, "krateo/argocd/endpoint", map[string]string{"k": "v"}); err == nil {
+		t.Fatalf("Write(...): want error with a revoked token, got nil")
+	}
+	if _, err := vault.NewClient(ctx, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Logins(); got != 2 {
+		t.Errorf("NewClient(...): want a new login after a permission error, got %d logins", got)
+	}
+}
@@ -0,0 +1,215 @@
+// Package vaulttest provides an in-process stand-in for the Vault HTTP API,
+// implementing the KV v2 secrets engine and the Kubernetes auth method.
+package vaulttest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an in-memory Vault KV v2 server.
+type Server struct {
+	*httptest.Server
+
+	// Token accepted by the server.
+	Token string
+	// Mount path of the KV v2 engine.
+	Mount string
+	// Roles maps the Kubernetes auth roles to the accepted service account tokens.
+	Roles map[string]string
+	// LeaseDuration of the tokens issued by a login, in seconds.
+	LeaseDuration int
+	// FailWrites makes the writes of the supplied kind ("data" or
+	// "metadata") fail with an internal server error.
+	FailWrites string
+	// Delay of every response.
+	Delay time.Duration
+
+	mu         sync.Mutex
+	data       map[string]map[string]string
+	metadata   map[string]map[string]string
+	namespaces []string
+	logins     int
+}
+
+// NewServer starts a new Vault stand-in accepting the supplied token.
+func NewServer(token string) *Server {
+	s := &Server{
+		Token:    token,
+		Mount:    "secret",
+		Roles:    map[string]string{},
+		data:     map[string]map[string]string{},
+		metadata: map[string]map[string]string{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Data returns the latest data stored at the supplied path.
+func (s *Server) Data(path string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[path]
+}
+
+// Namespaces returns the X-Vault-Namespace header of every received request.
+func (s *Server) Namespaces() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.namespaces...)
+}
+
+// Logins returns the number of successful Kubernetes auth logins.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// SetData stores data and custom metadata at the supplied path.
+func (s *Server) SetData(path string, data, meta map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[path] = data
+	s.metadata[path] = meta
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(s.Delay)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.namespaces = append(s.namespaces, r.Header.Get("X-Vault-Namespace"))
+
+	if strings.HasPrefix(r.URL.Path, "/v1/auth/") && strings.HasSuffix(r.URL.Path, "/login") {
+		s.login(w, r)
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != s.Token {
+		writeErrors(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	prefix := "/v1/" + s.Mount + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeErrors(w, http.StatusNotFound)
+		return
+	}
+
+	kind, path, ok := cut(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if !ok || len(path) == 0 {
+		writeErrors(w, http.StatusNotFound)
+		return
+	}
+
+	if kind == s.FailWrites && (r.Method == http.MethodPost || r.Method == http.MethodPut) {
+		writeErrors(w, http.StatusInternalServerError, "injected failure")
+		return
+	}
+
+	switch {
+	case kind == "data" && r.Method == http.MethodGet:
+		data, ok := s.data[path]
+		if !ok {
+			writeErrors(w, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"data": map[string]interface{}{"data": data},
+		})
+
+	case kind == "data" && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		var body struct {
+			Data map[string]string `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeErrors(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.data[path] = body.Data
+		if _, ok := s.metadata[path]; !ok {
+			s.metadata[path] = map[string]string{}
+		}
+		writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"version": 1}})
+
+	case kind == "metadata" && r.Method == http.MethodGet:
+		meta, ok := s.metadata[path]
+		if !ok {
+			writeErrors(w, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"data": map[string]interface{}{"custom_metadata": meta},
+		})
+
+	case kind == "metadata" && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		var body struct {
+			CustomMetadata map[string]string `json:"custom_metadata"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeErrors(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.metadata[path] = body.CustomMetadata
+		w.WriteHeader(http.StatusNoContent)
+
+	case kind == "metadata" && r.Method == http.MethodDelete:
+		delete(s.data, path)
+		delete(s.metadata, path)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Role string `json:"role"`
+		JWT  string `json:"jwt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if jwt, ok := s.Roles[body.Role]; !ok || jwt != body.JWT {
+		writeErrors(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	s.logins++
+	writeJSON(w, map[string]interface{}{
+		"auth": map[string]interface{}{
+			"client_token":   s.Token,
+			"lease_duration": s.LeaseDuration,
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeErrors(w http.ResponseWriter, code int, errs ...string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if errs == nil {
+		errs = []string{}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": errs})
+}
+
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -13,7 +13,9 @@ import (
 func Setup(mgr ctrl.Manager, o controller.Options) error {
 	for _, setup := range []func(ctrl.Manager, controller.Options) error{
 		config.Setup,
+		config.SetupHealth,
 		endpoint.Setup,
+		endpoint.SetupNamespaced,
 	} {
 		if err := setup(mgr, o); err != nil {
 			return err
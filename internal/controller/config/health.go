@@ -0,0 +1,164 @@
+package config
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/providerconfig"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/accounts"
+)
+
+const (
+	healthCheckTimeout = 30 * time.Second
+
+	errGetPC          = "cannot get ProviderConfig"
+	errUpdateStatus   = "cannot update ProviderConfig status"
+	reasonHealthCheck = event.Reason("HealthCheck")
+)
+
+// SetupHealth adds a controller that periodically checks the ArgoCD server
+// of each ProviderConfig and publishes the outcome in its status.
+func SetupHealth(mgr ctrl.Manager, o controller.Options) error {
+	name := "health/" + providerconfig.ControllerName(v1alpha1.ProviderConfigGroupKind)
+
+	r := &healthReconciler{
+		kube:     mgr.GetClient(),
+		log:      o.Logger.WithValues("controller", name),
+		record:   event.NewAPIRecorder(mgr.GetEventRecorderFor(name)),
+		interval: o.PollInterval,
+	}
+
+	// only the ConfigMaps and Services referenced by a ProviderConfig are of interest
+	refs := clients.NewReferenceIndex()
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.ProviderConfig{}, builder.WithPredicates(refs.Track(), predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &corev1.Service{}}, handler.EnqueueRequestsFromMapFunc(providerConfigsForServer(mgr.GetClient())),
+			builder.WithPredicates(refs.Referenced())).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(providerConfigsForServer(mgr.GetClient())),
+			builder.WithPredicates(refs.Referenced())).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+type healthReconciler struct {
+	kube     client.Client
+	log      logging.Logger
+	record   event.Recorder
+	interval time.Duration
+}
+
+func (r *healthReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := r.log.WithValues("request", req)
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	pc := &v1alpha1.ProviderConfig{}
+	if err := r.kube.Get(ctx, req.NamespacedName, pc); err != nil {
+		return reconcile.Result{}, errors.Wrap(client.IgnoreNotFound(err), errGetPC)
+	}
+	if pc.GetDeletionTimestamp() != nil {
+		return reconcile.Result{}, nil
+	}
+
+	orig := pc.DeepCopy()
+
+	version, cond := r.check(ctx, pc)
+	if cond.Reason == v1alpha1.ReasonHealthy {
+		pc.Status.Version = version
+		pc.Status.SetConditions(cond, xpv1.Available())
+	} else {
+		log.Debug("ArgoCD server health check failed", "reason", cond.Reason, "error", cond.Message)
+		if pc.Status.GetCondition(v1alpha1.TypeHealthy).Reason != cond.Reason {
+			r.record.Event(pc, event.Warning(reasonHealthCheck, errors.New(cond.Message)))
+		}
+		pc.Status.SetConditions(cond, xpv1.Unavailable())
+	}
+
+	now := metav1.Now()
+	pc.Status.LastHealthCheckTime = &now
+
+	if err := r.kube.Status().Patch(ctx, pc, client.MergeFrom(orig)); err != nil {
+		return reconcile.Result{}, errors.Wrap(client.IgnoreNotFound(err), errUpdateStatus)
+	}
+
+	return reconcile.Result{RequeueAfter: r.interval}, nil
+}
+
+// check verifies that the ArgoCD server is reachable, reports its version
+// and accepts the ProviderConfig credentials.
+func (r *healthReconciler) check(ctx context.Context, pc *v1alpha1.ProviderConfig) (string, xpv1.Condition) {
+	opts, err := clients.NewTokenProviderOptions(ctx, r.kube, pc)
+	if err != nil {
+		return "", v1alpha1.Unhealthy(v1alpha1.ReasonInvalidConfig, err.Error())
+	}
+
+	version, err := accounts.GetVersion(ctx, opts)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return "", v1alpha1.Unhealthy(v1alpha1.ReasonUnreachable, err.Error())
+		}
+		return "", v1alpha1.Unhealthy(v1alpha1.ReasonVersionFailed, err.Error())
+	}
+
+	caps, err := accounts.CapabilitiesOf(opts.Backend)
+	if err != nil {
+		return version, v1alpha1.Unhealthy(v1alpha1.ReasonInvalidConfig, err.Error())
+	}
+	if !caps.Session {
+		// The backend issues tokens without a session: nothing to check.
+		return version, v1alpha1.Healthy()
+	}
+
+	pass, err := clients.GetInitialAdminPassword(ctx, r.kube, pc)
+	if err != nil {
+		return version, v1alpha1.Unhealthy(v1alpha1.ReasonInvalidConfig, err.Error())
+	}
+
+	token, err := accounts.Login(ctx, opts, "admin", pass)
+	if err == nil && len(strings.TrimSpace(token)) == 0 {
+		err = errors.New("empty session token")
+	}
+	if err != nil {
+		return version, v1alpha1.Unhealthy(v1alpha1.ReasonLoginFailed, err.Error())
+	}
+
+	return version, v1alpha1.Healthy()
+}
+
+// providerConfigsForServer enqueues the ProviderConfigs whose server url
+// depends on the supplied Service or ConfigMap.
+func providerConfigsForServer(kube client.Client) func(client.Object) []reconcile.Request {
+	return func(o client.Object) []reconcile.Request {
+		res := []reconcile.Request{}
+		for _, name := range clients.ProviderConfigsForServer(context.Background(), kube, o) {
+			res = append(res, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		}
+		return res
+	}
+}
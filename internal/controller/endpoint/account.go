@@ -0,0 +1,107 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/accounts"
+)
+
+const (
+	errGetAccountsConfigMap = "cannot get argocd accounts ConfigMap"
+)
+
+// observeAccount sets the Account condition of the Endpoint from the ArgoCD
+// accounts ConfigMap, if any; it returns false if the account cannot issue
+// tokens.
+func (e *external) observeAccount(ctx context.Context, cr endpoint) (bool, error) {
+	if e.accountsRef == nil {
+		return true, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := e.kube.Get(ctx, types.NamespacedName{Name: e.accountsRef.Name, Namespace: e.accountsRef.Namespace}, cm)
+	if err != nil {
+		return false, errors.Wrap(err, errGetAccountsConfigMap)
+	}
+
+	state := accounts.AccountStateFromConfigMap(cm.Data, cr.GetParameters().Account)
+	cr.SetConditions(accountCondition(state, cr.GetParameters().Account, e.accountsRef))
+
+	return state == accounts.AccountAvailable, nil
+}
+
+// accountCondition returns the Account condition matching the supplied state.
+func accountCondition(state accounts.AccountState, account string, ref *v1alpha1.ConfigMapReference) xpv1.Condition {
+	where := fmt.Sprintf("%s/%s", ref.Namespace, ref.Name)
+
+	switch state {
+	case accounts.AccountRemoved:
+		return endpointsv1alpha1.AccountRemoved(fmt.Sprintf("account '%s' is not declared in %s", account, where))
+	case accounts.AccountDisabled:
+		return endpointsv1alpha1.AccountDisabled(fmt.Sprintf("account '%s' is disabled in %s", account, where))
+	case accounts.AccountNoAPIKey:
+		return endpointsv1alpha1.AccountNoAPIKey(fmt.Sprintf("account '%s' lacks the apiKey capability in %s", account, where))
+	default:
+		return endpointsv1alpha1.AccountAvailable()
+	}
+}
+
+// endpointsForAccountsConfigMap enqueues the Endpoints affected by a change
+// of an ArgoCD accounts ConfigMap: the ones whose account is no longer
+// usable and the ones whose account is usable again.
+func endpointsForAccountsConfigMap(kube client.Client, list lister) func(client.Object) []reconcile.Request {
+	return func(o client.Object) []reconcile.Request {
+		cm, ok := o.(*corev1.ConfigMap)
+		if !ok {
+			return nil
+		}
+
+		pcs := &v1alpha1.ProviderConfigList{}
+		if err := kube.List(context.Background(), pcs); err != nil {
+			return nil
+		}
+
+		names := map[string]bool{}
+		for _, pc := range pcs.Items {
+			ref := pc.Spec.AccountsConfigMapRef
+			if ref != nil && ref.Name == cm.Name && ref.Namespace == cm.Namespace {
+				names[pc.Name] = true
+			}
+		}
+		if len(names) == 0 {
+			return nil
+		}
+
+		items, err := list(context.Background(), kube)
+		if err != nil {
+			return nil
+		}
+
+		res := []reconcile.Request{}
+		for _, el := range items {
+			if !names[el.providerConfig] {
+				continue
+			}
+
+			state := accounts.AccountStateFromConfigMap(cm.Data, el.GetParameters().Account)
+			wasAvailable := el.GetCondition(endpointsv1alpha1.TypeAccount).Status != corev1.ConditionFalse
+			if (state == accounts.AccountAvailable) == wasAvailable {
+				continue
+			}
+
+			res = append(res, el.request())
+		}
+		return res
+	}
+}
@@ -0,0 +1,48 @@
+package endpoint
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+const errUpdateCriticalAnnotations = "cannot update critical annotations"
+
+// observationKeeper is a managed.CriticalAnnotationUpdater keeping the
+// observation recorded by Create. The default one reloads the endpoint
+// before persisting the annotations, losing the id of the issued token,
+// which could then never be revoked. It also looks up the namespaced
+// endpoints by namespace and name.
+type observationKeeper struct {
+	kube client.Client
+}
+
+// UpdateCriticalAnnotations persists the annotations of the supplied
+// endpoint, retrying on API server errors.
+func (u *observationKeeper) UpdateCriticalAnnotations(ctx context.Context, o client.Object) error {
+	cr, ok := o.(endpoint)
+	if !ok {
+		return errors.New(errNotEndpoint)
+	}
+
+	a := cr.GetAnnotations()
+	obs := *cr.GetObservation()
+
+	err := retry.OnError(retry.DefaultRetry, resource.IsAPIError, func() error {
+		if err := u.kube.Get(ctx, client.ObjectKeyFromObject(cr), cr); err != nil {
+			return err
+		}
+		meta.AddAnnotations(cr, a)
+		return u.kube.Update(ctx, cr)
+	})
+
+	// the status is persisted by the reconciler right after
+	*cr.GetObservation() = obs
+
+	return errors.Wrap(err, errUpdateCriticalAnnotations)
+}
@@ -0,0 +1,65 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis"
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+)
+
+func TestUpdateCriticalAnnotations(t *testing.T) {
+	cases := map[string]struct {
+		cr endpoint
+	}{
+		"Endpoint": {
+			cr: &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "dashboard"}},
+		},
+		"NamespacedEndpoint": {
+			cr: &endpointsv1alpha1.NamespacedEndpoint{ObjectMeta: metav1.ObjectMeta{Name: "dashboard", Namespace: "team-a"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			s := runtime.NewScheme()
+			if err := apis.AddToScheme(s); err != nil {
+				t.Fatal(err)
+			}
+			kube := fake.NewClientBuilder().WithScheme(s).WithObjects(tc.cr).Build()
+
+			// Create records the issued token and the reconciler the
+			// creation annotations.
+			want := endpointsv1alpha1.EndpointObservation{ID: "3f5e", ServerURL: "https://argocd.example.com"}
+			*tc.cr.GetObservation() = want
+			meta.SetExternalCreateSucceeded(tc.cr, metav1.Now().Time)
+
+			u := &observationKeeper{kube: kube}
+			if err := u.UpdateCriticalAnnotations(ctx, tc.cr); err != nil {
+				t.Fatalf("UpdateCriticalAnnotations(...): %v", err)
+			}
+
+			if diff := cmp.Diff(want, *tc.cr.GetObservation()); diff != "" {
+				t.Errorf("UpdateCriticalAnnotations(...): -want observation, +got observation:\n%s", diff)
+			}
+
+			got := tc.cr.DeepCopyObject().(endpoint)
+			if err := kube.Get(ctx, client.ObjectKeyFromObject(tc.cr), got); err != nil {
+				t.Fatal(err)
+			}
+			if meta.GetExternalCreateSucceeded(got).IsZero() {
+				t.Errorf("UpdateCriticalAnnotations(...): want the %s annotation persisted", meta.AnnotationKeyExternalCreateSucceeded)
+			}
+		})
+	}
+}
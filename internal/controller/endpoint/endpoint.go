@@ -2,30 +2,52 @@ package endpoint
 
 import (
 	"context"
+	"crypto/rsa"
+	"fmt"
+	"time"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection"
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 
 	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
 	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients"
 	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/accounts"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/encryption"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/features"
 
 	corev1 "k8s.io/api/core/v1"
 )
 
 const (
 	errNotEndpoint = "managed resource is not an argocd endpoint custom resource"
-	//errGetPC          = "cannot get ProviderConfig"
+	errNoSink      = "either writeSecretToRef or vaultConfigRef must be specified"
+	errEncrypt     = "cannot encrypt argocd token"
+	errExpiresIn   = "invalid expiresIn duration"
+	errListTokens  = "cannot list argocd account tokens"
+	errRevokeToken = "cannot revoke argocd account token"
+
+	errKeyFingerprint = "cannot compute public key fingerprint"
+
+	errFmtNamespaceNotAllowed       = "ProviderConfig does not allow writing secrets to namespace %s"
+	errFmtConnectionSecretNamespace = "NamespacedEndpoint cannot write its connection secret to namespace %s"
 	//errFmtKeyNotFound = "key %s is not found in referenced Kubernetes secret"
 )
 
@@ -37,6 +59,11 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 
 	recorder := mgr.GetEventRecorderFor(name)
 
+	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
+	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
+		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), v1alpha1.StoreConfigGroupVersionKind))
+	}
+
 	r := managed.NewReconciler(mgr,
 		resource.ManagedKind(endpointsv1alpha1.EndpointGroupVersionKind),
 		managed.WithExternalConnecter(&connector{
@@ -44,16 +71,42 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 			log:  log,
 			rec:  recorder,
 		}),
+		managed.WithInitializers(
+			managed.NewNameAsExternalName(mgr.GetClient()),
+			&providerConfigSelector{kube: mgr.GetClient()}),
+		managed.WithCriticalAnnotationUpdater(&observationKeeper{kube: mgr.GetClient()}),
+		managed.WithConnectionPublishers(cps...),
 		managed.WithLogger(log),
 		managed.WithRecorder(event.NewAPIRecorder(recorder)))
 
+	// only the ConfigMaps and Services referenced by a ProviderConfig are of interest
+	refs := clients.NewReferenceIndex()
+
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		WithOptions(o.ForControllerRuntime()).
 		For(&endpointsv1alpha1.Endpoint{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(endpointsForNamespace(mgr.GetClient()))).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(endpointForSecret),
+			builder.WithPredicates(predicate.NewPredicateFuncs(isEndpointSecret))).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(endpointsForAccountsConfigMap(mgr.GetClient(), listEndpoints)),
+			builder.WithPredicates(refs.Referenced())).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(endpointsForServer(mgr.GetClient(), listEndpoints)),
+			builder.WithPredicates(refs.Referenced())).
+		Watches(&source.Kind{Type: &corev1.Service{}}, handler.EnqueueRequestsFromMapFunc(endpointsForServer(mgr.GetClient(), listEndpoints)),
+			builder.WithPredicates(refs.Referenced())).
+		Watches(&source.Kind{Type: &v1alpha1.ProviderConfig{}}, handler.EnqueueRequestsFromMapFunc(endpointsForProviderConfig(mgr.GetClient(), listEndpoints)),
+			builder.WithPredicates(refs.Track(), predicate.GenerationChangedPredicate{})).
 		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
 }
 
+// endpoint is the managed resource reconciled by this package: either an
+// Endpoint or a NamespacedEndpoint.
+type endpoint interface {
+	resource.Managed
+	endpointsv1alpha1.TokenEndpoint
+}
+
 type connector struct {
 	kube client.Client
 	log  logging.Logger
@@ -61,7 +114,7 @@ type connector struct {
 }
 
 func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
-	cr, ok := mg.(*endpointsv1alpha1.Endpoint)
+	cr, ok := mg.(endpoint)
 	if !ok {
 		return nil, errors.New(errNotEndpoint)
 	}
@@ -73,12 +126,45 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 
 	c.log.Debug("Created session", "token", cfg.AuthToken)
 
-	return &external{
+	pc, pcb, err := clients.GetProviderConfig(ctx, c.kube, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	caps, err := accounts.CapabilitiesOf(cfg.Backend)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := &external{
 		kube: c.kube,
 		log:  c.log,
 		cfg:  cfg,
+		caps: caps,
 		rec:  c.rec,
-	}, nil
+		gen:  pc.GetGeneration(),
+
+		accountsRef: pc.Spec.AccountsConfigMapRef,
+		allowed:     pc.Spec.AllowedNamespaces,
+		policies:    []*v1alpha1.AccountPolicy{pc.Spec.AccountPolicy},
+	}
+	if pcb != nil {
+		ext.policies = append(ext.policies, pcb.Spec.AccountPolicy)
+	}
+
+	if enc := cr.GetParameters().Encryption; enc != nil {
+		ext.pub, err = clients.GetPublicKey(ctx, c.kube, &enc.PublicKeyRef)
+		if err != nil {
+			return nil, err
+		}
+
+		ext.kid, err = encryption.KeyFingerprint(ext.pub)
+		if err != nil {
+			return nil, errors.Wrap(err, errKeyFingerprint)
+		}
+	}
+
+	return ext, nil
 }
 
 // An ExternalClient observes, then either creates, updates, or deletes an
@@ -87,89 +173,606 @@ type external struct {
 	kube client.Client
 	log  logging.Logger
 	cfg  *accounts.TokenProviderOptions
+	caps accounts.Capabilities
 	rec  record.EventRecorder
+	// gen is the generation of the ProviderConfig cfg has been built from.
+	gen int64
+	// accountsRef is the ArgoCD accounts ConfigMap, if any.
+	accountsRef *v1alpha1.ConfigMapReference
+	// allowed are the namespaces secrets may be written to; all if empty.
+	allowed []string
+	// policies restrict the tokens that may be issued: the ones of the
+	// ProviderConfig and of the ProviderConfigBinding, if any.
+	policies []*v1alpha1.AccountPolicy
+
+	// pub is the key the token is encrypted with, kid its fingerprint;
+	// both are unset if the token is stored in plaintext.
+	pub *rsa.PublicKey
+	kid string
 }
 
 func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
-	cr, ok := mg.(*endpointsv1alpha1.Endpoint)
+	cr, ok := mg.(endpoint)
 	if !ok {
 		return managed.ExternalObservation{}, errors.New(errNotEndpoint)
 	}
 
-	spec := cr.Spec.ForProvider.DeepCopy()
+	spec := cr.GetParameters()
 
-	token, err := clients.GetEndpointSecret(ctx, e.kube, &spec.WriteSecretToRef)
+	err := clients.ValidateSecretTemplate(spec.SecretTemplate)
+	if err == nil && spec.Encryption != nil {
+		err = clients.ValidateEncryptedSecretTemplate(spec.SecretTemplate)
+	}
 	if err != nil {
-		return managed.ExternalObservation{}, err
+		// A broken template will not fix itself: report it and wait
+		// for the spec to change instead of retrying.
+		cr.SetConditions(endpointsv1alpha1.TemplateError(err.Error()), xpv1.Unavailable())
+		return managed.ExternalObservation{
+			ResourceExists:   true,
+			ResourceUpToDate: true,
+		}, nil
+	}
+	cr.SetConditions(endpointsv1alpha1.TemplateValid())
+
+	// Never block the deletion of an Endpoint created before the
+	// ProviderConfig restrictions.
+	if !meta.WasDeleted(cr) {
+		if err := checkNamespaces(cr, e.allowed); err != nil {
+			return managed.ExternalObservation{}, err
+		}
+
+		if err := e.checkPolicy(cr); err != nil {
+			return managed.ExternalObservation{}, err
+		}
 	}
 
-	if len(token) > 0 {
-		cr.SetConditions(xpv1.Available())
+	sinks, refs, err := e.sinks(ctx, cr)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
 
-		// TODO handle token expiration?
+	usable, err := e.observeAccount(ctx, cr)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if !usable {
+		// No token can be issued for the account: leave the Endpoint
+		// alone until the account is usable again.
+		if spec.AccountRemovalPolicy == endpointsv1alpha1.AccountRemovalDeleteSecret {
+			if err := e.remove(ctx, cr, sinks); err != nil {
+				return managed.ExternalObservation{}, err
+			}
+		}
+		cr.SetConditions(xpv1.Unavailable())
 		return managed.ExternalObservation{
 			ResourceExists:   true,
 			ResourceUpToDate: true,
 		}, nil
 	}
 
+	token, err := e.token(ctx, cr, sinks[0])
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+
+	if len(token) == 0 {
+		return managed.ExternalObservation{
+			ResourceExists:   false,
+			ResourceUpToDate: true,
+		}, nil
+	}
+
+	if msg := e.checkToken(cr, token); len(msg) > 0 {
+		// The stored token has been tampered with, or it cannot be
+		// re-encrypted without the private key: issue a new one.
+		e.rec.Eventf(cr, corev1.EventTypeWarning, "TokenInvalid", "%s: issuing a new token", msg)
+		return managed.ExternalObservation{
+			ResourceExists:   false,
+			ResourceUpToDate: true,
+		}, nil
+	}
+
+	if url := cr.GetObservation().ServerURL; len(url) > 0 && url != e.cfg.ServerUrl {
+		// The token has been issued by another ArgoCD instance.
+		e.rec.Eventf(cr, corev1.EventTypeNormal, "ServerChanged", "ArgoCD server changed from %s to %s: issuing a new token", url, e.cfg.ServerUrl)
+		return managed.ExternalObservation{
+			ResourceExists:   false,
+			ResourceUpToDate: true,
+		}, nil
+	}
+
+	upToDate, err := e.isUpToDate(ctx, cr, token, sinks, refs)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if cr.GetObservation().ProviderConfigGeneration != e.gen {
+		// The ProviderConfig has changed since the secret has been
+		// written, e.g. its CA or credentials: rewrite it.
+		upToDate = false
+	}
+	if upToDate {
+		e.observeProviderConfig(cr)
+	}
+	cr.SetConditions(endpointsv1alpha1.SecretOwned(), xpv1.Available())
+
 	return managed.ExternalObservation{
-		ResourceExists:   false,
-		ResourceUpToDate: true,
+		ResourceExists:    true,
+		ResourceUpToDate:  upToDate,
+		ConnectionDetails: e.connectionDetails(token),
 	}, nil
 }
 
 func (e *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
-	cr, ok := mg.(*endpointsv1alpha1.Endpoint)
+	cr, ok := mg.(endpoint)
 	if !ok {
 		return managed.ExternalCreation{}, errors.New(errNotEndpoint)
 	}
 
 	cr.SetConditions(xpv1.Creating())
 
-	spec := cr.Spec.ForProvider.DeepCopy()
+	spec := cr.GetParameters()
+
+	sinks, _, err := e.sinks(ctx, cr)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+
+	var expiresIn time.Duration
+	if len(spec.ExpiresIn) > 0 {
+		expiresIn, err = time.ParseDuration(spec.ExpiresIn)
+		if err != nil {
+			return managed.ExternalCreation{}, errors.Wrap(err, errExpiresIn)
+		}
+	}
+	if expiresIn > 0 && !e.caps.Expiry {
+		e.rec.Eventf(cr, corev1.EventTypeWarning, "ExpiryNotSupported", "Token provider %s does not support expiration: issuing a token that never expires", e.backend())
+		expiresIn = 0
+	}
+
+	if err := e.checkAccountPolicies(spec.Account, expiresIn); err != nil {
+		e.rec.Eventf(cr, corev1.EventTypeWarning, "PolicyViolation", "Refused to issue argocd token: %s", err.Error())
+		return managed.ExternalCreation{}, err
+	}
 
-	token, err := accounts.GenerateToken(e.cfg, spec.Account, 0)
+	e.reportOrphanedToken(cr)
+
+	for _, id := range e.replacedTokens(cr) {
+		if err := e.revoke(ctx, spec.Account, id); err != nil {
+			return managed.ExternalCreation{}, err
+		}
+	}
+
+	token, err := accounts.GenerateToken(ctx, e.cfg, spec.Account, spec.ID, int64(expiresIn.Seconds()))
 	if err != nil {
 		return managed.ExternalCreation{}, err
 	}
 	e.log.Debug("Generated argocd token", "account", spec.Account)
 	e.rec.Eventf(cr, corev1.EventTypeNormal, "TokenCreated", "Generated argocd token for account: %s", spec.Account)
 
-	err = clients.CreateEndpointSecret(ctx, e.kube, clients.CreateSecretOpts{
-		Token:     token,
-		TargetURL: e.cfg.ServerUrl,
-		SecretRef: &spec.WriteSecretToRef,
-	})
-	if err != nil {
+	e.observeToken(cr, token)
+
+	if e.pub != nil {
+		token, err = encryption.Encrypt(e.pub, token)
+		if err != nil {
+			return managed.ExternalCreation{}, errors.Wrap(err, errEncrypt)
+		}
+	}
+
+	if err := e.write(ctx, cr, token, sinks); err != nil {
+		e.discard(ctx, cr, sinks)
 		return managed.ExternalCreation{}, err
 	}
-	e.log.Debug("Saved argocd token as secret", "account", spec.Account, "secret", spec.WriteSecretToRef.Name)
-	e.rec.Eventf(cr, corev1.EventTypeNormal, "TokenSaved", "Saved argocd token for account '%s' into '%s' secret", spec.Account, spec.WriteSecretToRef.Name)
+	e.observeProviderConfig(cr)
 
-	return managed.ExternalCreation{}, nil
+	return managed.ExternalCreation{
+		ConnectionDetails: e.connectionDetails(token),
+	}, nil
 }
 
 func (e *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
-	return managed.ExternalUpdate{}, nil // noop
+	cr, ok := mg.(endpoint)
+	if !ok {
+		return managed.ExternalUpdate{}, errors.New(errNotEndpoint)
+	}
+
+	sinks, refs, err := e.sinks(ctx, cr)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+
+	// Rewrite the secrets content keeping the already issued token.
+	token, err := e.token(ctx, cr, sinks[0])
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+
+	if err := e.write(ctx, cr, token, sinks); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	e.observeProviderConfig(cr)
+
+	return managed.ExternalUpdate{}, e.prune(ctx, cr, refs)
 }
 
 func (e *external) Delete(ctx context.Context, mg resource.Managed) error {
-	cr, ok := mg.(*endpointsv1alpha1.Endpoint)
+	cr, ok := mg.(endpoint)
 	if !ok {
 		return errors.New(errNotEndpoint)
 	}
 
 	cr.SetConditions(xpv1.Deleting())
 
-	spec := cr.Spec.ForProvider.DeepCopy()
+	spec := cr.GetParameters()
+
+	sinks, _, err := e.sinks(ctx, cr)
+	if err != nil {
+		return err
+	}
+
+	if err := e.remove(ctx, cr, sinks); err != nil {
+		return err
+	}
+
+	if id := cr.GetObservation().ID; len(id) > 0 {
+		if err := e.revoke(ctx, spec.Account, id); err != nil {
+			return err
+		}
+	}
+	e.rec.Eventf(cr, corev1.EventTypeNormal, "TokenDeleted", "Deleted argocd token for account '%s'", spec.Account)
+
+	return nil
+}
+
+// remove deletes the token from all the supplied sinks, and all the copies.
+func (e *external) remove(ctx context.Context, cr endpoint, sinks []clients.Sink) error {
+	for _, s := range sinks {
+		e.log.Debug("Deleting argocd token", "account", cr.GetParameters().Account, "sink", s.String())
+
+		err := s.Delete(ctx)
+		if clients.IsSecretNotOwned(err) {
+			// Never remove a secret we did not create, but do not block
+			// the deletion of the Endpoint either.
+			cr.SetConditions(endpointsv1alpha1.SecretNotOwned(err.Error()))
+			e.rec.Eventf(cr, corev1.EventTypeWarning, "SecretNotOwned", "Skipped deletion of %s: %s", s.String(), err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Remove all the copies too.
+	return e.prune(ctx, cr, nil)
+}
+
+// sinks returns all the places the Endpoint writes its token to, along with
+// the references of the Kubernetes secrets among them.
+func (e *external) sinks(ctx context.Context, cr endpoint) ([]clients.Sink, []xpv1.SecretReference, error) {
+	refs, err := secretRefs(ctx, e.kube, cr, e.allowed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res := make([]clients.Sink, 0, len(refs)+1)
+	for _, ref := range refs {
+		res = append(res, clients.NewKubernetesSink(e.kube, ref, cr))
+	}
+
+	if ref := cr.GetParameters().VaultConfigRef; ref != nil {
+		s, err := clients.NewVaultSink(ctx, e.kube, ref, cr, cr.GetParameters().Account)
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, s)
+	}
+
+	if len(res) == 0 {
+		return nil, nil, errors.New(errNoSink)
+	}
+
+	return res, refs, nil
+}
+
+// token returns the token stored in the supplied sink; it reports a
+// SecretNotOwned condition if the secret has not been created by the Endpoint.
+func (e *external) token(ctx context.Context, cr endpoint, s clients.Sink) (string, error) {
+	token, err := s.Token(ctx)
+	if clients.IsSecretNotOwned(err) {
+		cr.SetConditions(endpointsv1alpha1.SecretNotOwned(err.Error()))
+	}
+	return token, err
+}
+
+// observeProviderConfig records the ArgoCD server the token is issued by
+// and the generation of the ProviderConfig the secret is written with.
+func (e *external) observeProviderConfig(cr endpoint) {
+	cr.GetObservation().ServerURL = e.cfg.ServerUrl
+	cr.GetObservation().ProviderConfigGeneration = e.gen
+}
+
+// observeToken records the id and the expiration of the supplied
+// plaintext token.
+func (e *external) observeToken(cr endpoint, token string) {
+	cr.GetObservation().ID = cr.GetParameters().ID
+	cr.GetObservation().ExpiresIn = cr.GetParameters().ExpiresIn
+	cr.GetObservation().ExpiresAt = nil
+
+	claims, err := accounts.ParseTokenClaims(token)
+	if err != nil {
+		return
+	}
+
+	cr.GetObservation().ID = claims.ID
+	if exp := claims.ExpirationTime(); !exp.IsZero() {
+		t := metav1.NewTime(exp)
+		cr.GetObservation().ExpiresAt = &t
+	}
+}
+
+// revoke deletes the token with the supplied id, if any and if the token
+// provider supports it.
+func (e *external) revoke(ctx context.Context, account, id string) error {
+	return e.revokeWith(ctx, e.cfg, account, id)
+}
+
+// replacedTokens returns the ids of the tokens a new one replaces on the
+// current ArgoCD server: the previously issued one, unless issued by
+// another server, and the one with the requested id, as token ids are
+// unique per account.
+func (e *external) replacedTokens(cr endpoint) []string {
+	res := []string{}
+
+	obs := cr.GetObservation()
+	if len(obs.ID) > 0 && (len(obs.ServerURL) == 0 || obs.ServerURL == e.cfg.ServerUrl) {
+		res = append(res, obs.ID)
+	}
+
+	if id := cr.GetParameters().ID; len(id) > 0 && id != obs.ID {
+		res = append(res, id)
+	}
+
+	return res
+}
+
+// discard revokes the just issued token that could not be saved, and
+// removes it from the sinks it has already been written to, so that a new
+// one is issued at the next reconcile. Failures are only reported.
+func (e *external) discard(ctx context.Context, cr endpoint, sinks []clients.Sink) {
+	account := cr.GetParameters().Account
+
+	obs := cr.GetObservation()
+	if len(obs.ID) > 0 {
+		if err := e.revoke(ctx, account, obs.ID); err != nil {
+			e.rec.Eventf(cr, corev1.EventTypeWarning, "RevokeFailed", "Cannot revoke unsaved argocd token %s: %s", obs.ID, err.Error())
+		}
+	}
+	obs.ID = ""
+	obs.ExpiresAt = nil
+
+	for _, s := range sinks {
+		// secrets not owned by the Endpoint are left alone
+		if err := s.Delete(ctx); err != nil && !clients.IsSecretNotOwned(err) {
+			e.rec.Eventf(cr, corev1.EventTypeWarning, "DeleteFailed", "Cannot delete unsaved argocd token from %s: %s", s.String(), err.Error())
+		}
+	}
+}
+
+// reportOrphanedToken reports the previously issued token as orphaned if
+// the server it has been issued by has changed since. The previous server
+// url is read from the status, which is not trusted enough to send the
+// ProviderConfig credentials to: the token is left to be revoked by hand.
+func (e *external) reportOrphanedToken(cr endpoint) {
+	obs := cr.GetObservation()
+	if len(obs.ServerURL) == 0 || obs.ServerURL == e.cfg.ServerUrl || len(obs.ID) == 0 {
+		return
+	}
+
+	e.rec.Eventf(cr, corev1.EventTypeWarning, "TokenOrphaned", "Argocd token %s of account %s issued by previous server %s has not been revoked", obs.ID, cr.GetParameters().Account, obs.ServerURL)
+}
+
+// revokeWith deletes the token with the supplied id using the supplied
+// config, if any and if the token provider supports it. A token whose
+// account has been removed is as good as revoked.
+func (e *external) revokeWith(ctx context.Context, cfg *accounts.TokenProviderOptions, account, id string) error {
+	if !e.caps.Revoke {
+		e.log.Debug("Token provider does not support revocation", "backend", e.backend(), "account", account, "id", id)
+		return nil
+	}
+
+	if e.caps.List {
+		tokens, err := accounts.ListTokens(ctx, cfg, account)
+		if accounts.IsNotFound(err) {
+			e.log.Debug("Argocd account not found: nothing to revoke", "account", account, "id", id)
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, errListTokens)
+		}
+		if !hasToken(tokens, id) {
+			return nil
+		}
+	}
+
+	if err := accounts.DeleteToken(ctx, cfg, account, id); err != nil && !accounts.IsNotFound(err) {
+		return errors.Wrap(err, errRevokeToken)
+	}
+	e.log.Debug("Revoked argocd token", "account", account, "id", id)
+
+	return nil
+}
 
-	e.log.Debug("Deleting argocd token secret", "account", spec.Account, "secret", spec.WriteSecretToRef.Name)
+// backend returns the name of the token provider backend.
+func (e *external) backend() accounts.Backend {
+	if len(e.cfg.Backend) == 0 {
+		return accounts.BackendAPI
+	}
+	return e.cfg.Backend
+}
+
+func hasToken(tokens []accounts.Token, id string) bool {
+	for _, el := range tokens {
+		if el.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPolicy returns an error if the account policy of the ProviderConfig
+// refuses the token requested by the Endpoint.
+func (e *external) checkPolicy(cr endpoint) error {
+	var expiresIn time.Duration
+	if exp := cr.GetParameters().ExpiresIn; len(exp) > 0 && e.caps.Expiry {
+		d, err := time.ParseDuration(exp)
+		if err != nil {
+			return errors.Wrap(err, errExpiresIn)
+		}
+		expiresIn = d
+	}
+
+	return e.checkAccountPolicies(cr.GetParameters().Account, expiresIn)
+}
+
+// checkAccountPolicies returns an error if any of the account policies
+// refuses the supplied token.
+func (e *external) checkAccountPolicies(account string, expiresIn time.Duration) error {
+	for _, p := range e.policies {
+		if err := clients.CheckAccountPolicy(p, account, expiresIn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkToken verifies, without the private key, that the stored token is
+// well formed, not expired and encrypted as the Endpoint requires; it
+// returns a message describing the mismatch, if any.
+func (e *external) checkToken(cr endpoint, token string) string {
+	if exp := cr.GetObservation().ExpiresAt; exp != nil && !exp.After(time.Now()) {
+		return fmt.Sprintf("stored token expired at %s", exp.UTC().Format(time.RFC3339))
+	}
+
+	env, err := encryption.Parse(token)
+	if e.pub == nil {
+		if err == nil {
+			return "stored token is encrypted but encryption is not configured"
+		}
+		if _, err := accounts.ParseTokenClaims(token); err != nil {
+			return fmt.Sprintf("stored token is not a valid argocd token: %s", err.Error())
+		}
+		return ""
+	}
 
-	err := clients.DeleteEndpointSecret(ctx, e.kube, &spec.WriteSecretToRef)
-	if err == nil {
-		e.rec.Eventf(cr, corev1.EventTypeNormal, "TokenDeleted", "Deleted argocd token for account '%s' into '%s' secret", spec.Account, spec.WriteSecretToRef.Name)
+	if err != nil {
+		return fmt.Sprintf("stored token is not encrypted: %s", err.Error())
 	}
 
-	return err
+	if env.KeyID != e.kid {
+		return fmt.Sprintf("stored token is encrypted with key %s instead of %s", env.KeyID, e.kid)
+	}
+
+	return ""
+}
+
+// isUpToDate returns true if all the sinks hold the expected content and
+// no stale secret copy is left around.
+func (e *external) isUpToDate(ctx context.Context, cr endpoint, token string, sinks []clients.Sink, refs []xpv1.SecretReference) (bool, error) {
+	for _, s := range sinks {
+		ok, err := s.IsUpToDate(ctx, e.secretOpts(cr, token))
+		if err != nil {
+			if clients.IsSecretNotOwned(err) {
+				cr.SetConditions(endpointsv1alpha1.SecretNotOwned(err.Error()))
+			}
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+
+	all, err := clients.ListEndpointSecrets(ctx, e.kube, cr)
+	if err != nil {
+		return false, err
+	}
+	for i := range all {
+		if !isReferenced(&all[i], refs) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// write stores the token into all the supplied sinks.
+func (e *external) write(ctx context.Context, cr endpoint, token string, sinks []clients.Sink) error {
+	for _, s := range sinks {
+		err := s.Write(ctx, e.secretOpts(cr, token))
+		if err != nil {
+			if clients.IsSecretNotOwned(err) {
+				cr.SetConditions(endpointsv1alpha1.SecretNotOwned(err.Error()))
+			}
+			return err
+		}
+		e.log.Debug("Saved argocd token", "account", cr.GetParameters().Account, "sink", s.String())
+		e.rec.Eventf(cr, corev1.EventTypeNormal, "TokenSaved", "Saved argocd token for account '%s' into %s", cr.GetParameters().Account, s.String())
+	}
+
+	return nil
+}
+
+// prune deletes all the Kubernetes secrets owned by the Endpoint that are
+// not in the supplied refs.
+func (e *external) prune(ctx context.Context, cr endpoint, keep []xpv1.SecretReference) error {
+	all, err := clients.ListEndpointSecrets(ctx, e.kube, cr)
+	if err != nil {
+		return err
+	}
+
+	for i := range all {
+		if isReferenced(&all[i], keep) {
+			continue
+		}
+
+		ref := xpv1.SecretReference{Name: all[i].Name, Namespace: all[i].Namespace}
+		if err := clients.DeleteEndpointSecret(ctx, e.kube, &ref, cr); err != nil {
+			return err
+		}
+		e.log.Debug("Deleted argocd token secret", "account", cr.GetParameters().Account, "secret", ref.Name, "namespace", ref.Namespace)
+	}
+
+	return nil
+}
+
+func (e *external) secretOpts(cr endpoint, token string) clients.CreateSecretOpts {
+	var expiresAt *time.Time
+	if t := cr.GetObservation().ExpiresAt; t != nil {
+		expiresAt = &t.Time
+	}
+
+	return clients.CreateSecretOpts{
+		Token:     token,
+		TokenID:   cr.GetObservation().ID,
+		ExpiresAt: expiresAt,
+		TargetURL: e.cfg.ServerUrl,
+		Account:   cr.GetParameters().Account,
+		CACert:    e.cfg.CACert,
+		Insecure:  e.cfg.Insecure,
+		GRPCWeb:   e.cfg.GRPCWeb,
+		Template:  cr.GetParameters().SecretTemplate,
+	}
+}
+
+// isEndpointSecret filters the secrets written by this provider for an Endpoint.
+func isEndpointSecret(o client.Object) bool {
+	key, ok := clients.EndpointOf(o)
+	return ok && len(key.Namespace) == 0
+}
+
+// connectionDetails returns the token and the ArgoCD server url as
+// connection details, so that they can be published by Crossplane.
+func (e *external) connectionDetails(token string) managed.ConnectionDetails {
+	return managed.ConnectionDetails{
+		xpv1.ResourceCredentialsSecretTokenKey:    []byte(token),
+		xpv1.ResourceCredentialsSecretEndpointKey: []byte(e.cfg.ServerUrl),
+	}
 }
@@ -0,0 +1,361 @@
+package endpoint
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis"
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/argocdtest"
+)
+
+const secretName = "dashboard-endpoint"
+
+// newExternal connects to the supplied ArgoCD stand-in with the supplied
+// objects in the cluster.
+func newExternal(t *testing.T, srv *argocdtest.Server, cr *endpointsv1alpha1.Endpoint, objs ...client.Object) (managed.ExternalClient, client.Client) {
+	t.Helper()
+
+	s := runtime.NewScheme()
+	if err := corev1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "argocd-initial-admin-secret", Namespace: "argocd"}}
+	admin.Data = map[string][]byte{corev1.BasicAuthPasswordKey: []byte(argocdtest.AdminPassword)}
+
+	pc := &v1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	pc.Spec.ServerUrl = srv.URL
+	pc.Spec.Credentials = &v1alpha1.ProviderCredentials{
+		Source: xpv1.CredentialsSourceSecret,
+		CommonCredentialSelectors: xpv1.CommonCredentialSelectors{
+			SecretRef: &xpv1.SecretKeySelector{
+				SecretReference: xpv1.SecretReference{Name: admin.Name, Namespace: admin.Namespace},
+				Key:             corev1.BasicAuthPasswordKey,
+			},
+		},
+	}
+
+	kube := fake.NewClientBuilder().WithScheme(s).WithObjects(append(objs, admin, pc, cr)...).Build()
+
+	c := &connector{kube: kube, log: logging.NewNopLogger(), rec: record.NewFakeRecorder(100)}
+	ext, err := c.Connect(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("Connect(...): %v", err)
+	}
+	return ext, kube
+}
+
+func newTestEndpoint() *endpointsv1alpha1.Endpoint {
+	cr := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "dashboard", UID: "1234"}}
+	cr.Spec.ProviderConfigReference = &xpv1.Reference{Name: "default"}
+	cr.Spec.ForProvider.Account = argocdtest.Account
+	cr.Spec.ForProvider.WriteSecretToRef = xpv1.SecretReference{Name: secretName, Namespace: "argocd"}
+	return cr
+}
+
+func TestCreateRevokesReplacedToken(t *testing.T) {
+	ctx := context.Background()
+
+	srv := argocdtest.NewServer()
+	defer srv.Close()
+
+	cr := newTestEndpoint()
+	ext, kube := newExternal(t, srv, cr)
+
+	if _, err := ext.Create(ctx, cr); err != nil {
+		t.Fatalf("Create(...): %v", err)
+	}
+
+	// the secret is lost: a new token is issued in place of the first one
+	sec := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "argocd"}}
+	if err := kube.Delete(ctx, sec); err != nil {
+		t.Fatal(err)
+	}
+
+	obs, err := ext.Observe(ctx, cr)
+	if err != nil {
+		t.Fatalf("Observe(...): %v", err)
+	}
+	if obs.ResourceExists {
+		t.Fatalf("Observe(...): want no token once the secret is deleted")
+	}
+
+	if _, err := ext.Create(ctx, cr); err != nil {
+		t.Fatalf("Create(...): %v", err)
+	}
+	if got := len(srv.Fake.Tokens(argocdtest.Account)); got != 1 {
+		t.Errorf("ArgoCD tokens: want 1 after replacement, got %d", got)
+	}
+
+	if err := ext.Delete(ctx, cr); err != nil {
+		t.Fatalf("Delete(...): %v", err)
+	}
+	if got := len(srv.Fake.Tokens(argocdtest.Account)); got != 0 {
+		t.Errorf("ArgoCD tokens: want 0 after delete, got %d", got)
+	}
+}
+
+func TestCreateRevokesUnsavedToken(t *testing.T) {
+	ctx := context.Background()
+
+	srv := argocdtest.NewServer()
+	defer srv.Close()
+
+	// a secret the Endpoint does not own cannot be written
+	foreign := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "argocd"}}
+
+	cr := newTestEndpoint()
+	ext, _ := newExternal(t, srv, cr, foreign)
+
+	if _, err := ext.Create(ctx, cr); err == nil {
+		t.Fatalf("Create(...): want error writing to a foreign secret, got nil")
+	}
+	if got := len(srv.Fake.Tokens(argocdtest.Account)); got != 0 {
+		t.Errorf("ArgoCD tokens: want 0 after a failed write, got %d", got)
+	}
+	if id := cr.Status.AtProvider.ID; len(id) > 0 {
+		t.Errorf("Endpoint status: want no token id after a failed write, got %s", id)
+	}
+}
+
+func TestCreateReportsOrphanedToken(t *testing.T) {
+	ctx := context.Background()
+
+	previous := argocdtest.NewServer()
+	defer previous.Close()
+	srv := argocdtest.NewServer()
+	defer srv.Close()
+
+	// the status claims the token has been issued by another server
+	cr := newTestEndpoint()
+	cr.Status.AtProvider = endpointsv1alpha1.EndpointObservation{ID: "3f5e", ServerURL: previous.URL}
+	ext, _ := newExternal(t, srv, cr)
+
+	if _, err := ext.Create(ctx, cr); err != nil {
+		t.Fatalf("Create(...): %v", err)
+	}
+	if got := previous.Calls(argocdtest.RouteSession); got != 0 {
+		t.Errorf("Create(...): want no login to the server read from the status, got %d", got)
+	}
+	if got := len(srv.Fake.Tokens(argocdtest.Account)); got != 1 {
+		t.Errorf("ArgoCD tokens: want 1 issued by the current server, got %d", got)
+	}
+
+	rec := ext.(*external).rec.(*record.FakeRecorder)
+	var events []string
+	for len(rec.Events) > 0 {
+		events = append(events, <-rec.Events)
+	}
+	if !containsEvent(events, "TokenOrphaned", "3f5e") {
+		t.Errorf("Create(...): want a TokenOrphaned event naming the token id, got %v", events)
+	}
+}
+
+func containsEvent(events []string, reason, s string) bool {
+	for _, el := range events {
+		if strings.Contains(el, reason) && strings.Contains(el, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestObserveProviderConfigGeneration(t *testing.T) {
+	ctx := context.Background()
+
+	srv := argocdtest.NewServer()
+	defer srv.Close()
+
+	cr := newTestEndpoint()
+	ext, kube := newExternal(t, srv, cr)
+
+	if _, err := ext.Create(ctx, cr); err != nil {
+		t.Fatalf("Create(...): %v", err)
+	}
+
+	// only the credentials of the ProviderConfig change
+	pc := &v1alpha1.ProviderConfig{}
+	if err := kube.Get(ctx, client.ObjectKey{Name: "default"}, pc); err != nil {
+		t.Fatal(err)
+	}
+	pc.SetGeneration(pc.GetGeneration() + 1)
+	if err := kube.Update(ctx, pc); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &connector{kube: kube, log: logging.NewNopLogger(), rec: record.NewFakeRecorder(100)}
+	ext, err := c.Connect(ctx, cr)
+	if err != nil {
+		t.Fatalf("Connect(...): %v", err)
+	}
+
+	obs, err := ext.Observe(ctx, cr)
+	if err != nil {
+		t.Fatalf("Observe(...): %v", err)
+	}
+	if !obs.ResourceExists || obs.ResourceUpToDate {
+		t.Fatalf("Observe(...): want an existing token to rewrite once the ProviderConfig changes, got %+v", obs)
+	}
+
+	if _, err := ext.Update(ctx, cr); err != nil {
+		t.Fatalf("Update(...): %v", err)
+	}
+	if got, want := cr.Status.AtProvider.ProviderConfigGeneration, pc.GetGeneration(); got != want {
+		t.Errorf("Update(...): want providerConfigGeneration %d, got %d", want, got)
+	}
+
+	obs, err = ext.Observe(ctx, cr)
+	if err != nil {
+		t.Fatalf("Observe(...): %v", err)
+	}
+	if !obs.ResourceUpToDate {
+		t.Errorf("Observe(...): want an up to date token once the secret is rewritten")
+	}
+}
+
+func TestDeleteRemovedAccount(t *testing.T) {
+	ctx := context.Background()
+
+	srv := argocdtest.NewServer()
+	defer srv.Close()
+
+	cr := newTestEndpoint()
+	ext, kube := newExternal(t, srv, cr)
+
+	if _, err := ext.Create(ctx, cr); err != nil {
+		t.Fatalf("Create(...): %v", err)
+	}
+
+	// the account is removed from argocd-cm along with its tokens
+	srv.Fake.RemoveAccount(argocdtest.Account)
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "argocd-cm", Namespace: "argocd"}}
+	if err := kube.Create(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	pc := &v1alpha1.ProviderConfig{}
+	if err := kube.Get(ctx, client.ObjectKey{Name: "default"}, pc); err != nil {
+		t.Fatal(err)
+	}
+	pc.Spec.AccountsConfigMapRef = &v1alpha1.ConfigMapReference{Name: cm.Name, Namespace: cm.Namespace}
+	if err := kube.Update(ctx, pc); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &connector{kube: kube, log: logging.NewNopLogger(), rec: record.NewFakeRecorder(100)}
+	ext, err := c.Connect(ctx, cr)
+	if err != nil {
+		t.Fatalf("Connect(...): %v", err)
+	}
+
+	obs, err := ext.Observe(ctx, cr)
+	if err != nil {
+		t.Fatalf("Observe(...): %v", err)
+	}
+	if !obs.ResourceExists {
+		t.Fatalf("Observe(...): want an existing resource while the account is removed")
+	}
+	if got := cr.GetCondition(endpointsv1alpha1.TypeAccount).Reason; got != endpointsv1alpha1.ReasonAccountRemoved {
+		t.Fatalf("Observe(...): want reason %s, got %s", endpointsv1alpha1.ReasonAccountRemoved, got)
+	}
+
+	if err := ext.Delete(ctx, cr); err != nil {
+		t.Errorf("Delete(...): want the token of a removed account treated as revoked, got %v", err)
+	}
+}
+
+func TestConnectNamespacedPublicKey(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkix, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})
+
+	cases := map[string]struct {
+		keyNamespace string
+		wantErr      bool
+	}{
+		"OwnNamespace": {
+			keyNamespace: "team-a",
+		},
+		"OtherNamespace": {
+			// the key is looked up in team-a, where there is none
+			keyNamespace: "argocd",
+			wantErr:      true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := argocdtest.NewServer()
+			defer srv.Close()
+
+			s := runtime.NewScheme()
+			if err := corev1.AddToScheme(s); err != nil {
+				t.Fatal(err)
+			}
+			if err := apis.AddToScheme(s); err != nil {
+				t.Fatal(err)
+			}
+
+			admin := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "argocd-initial-admin-secret", Namespace: "argocd"}}
+			admin.Data = map[string][]byte{corev1.BasicAuthPasswordKey: []byte(argocdtest.AdminPassword)}
+
+			pc := &v1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "argocd"}}
+			pc.Spec.ServerUrl = srv.URL
+			pc.Spec.Credentials = &v1alpha1.ProviderCredentials{
+				Source: xpv1.CredentialsSourceSecret,
+				CommonCredentialSelectors: xpv1.CommonCredentialSelectors{
+					SecretRef: &xpv1.SecretKeySelector{
+						SecretReference: xpv1.SecretReference{Name: admin.Name, Namespace: admin.Namespace},
+						Key:             corev1.BasicAuthPasswordKey,
+					},
+				},
+			}
+
+			pcb := &v1alpha1.ProviderConfigBinding{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "team-a"}}
+			pcb.Spec.ProviderConfigRef = xpv1.Reference{Name: pc.Name}
+
+			key := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "dashboard-key", Namespace: tc.keyNamespace}}
+			key.Data = map[string][]byte{"key.pem": pub}
+
+			cr := &endpointsv1alpha1.NamespacedEndpoint{ObjectMeta: metav1.ObjectMeta{Name: "dashboard", Namespace: "team-a", UID: "1234"}}
+			cr.Spec.ProviderConfigReference = &xpv1.Reference{Name: pcb.Name}
+			cr.Spec.ForProvider.Account = argocdtest.Account
+			cr.Spec.ForProvider.WriteSecretToRef = xpv1.LocalSecretReference{Name: secretName}
+			cr.Spec.ForProvider.Encryption = &endpointsv1alpha1.TokenEncryption{
+				PublicKeyRef: endpointsv1alpha1.PublicKeyReference{Name: key.Name, Namespace: tc.keyNamespace, Key: "key.pem"},
+			}
+
+			kube := fake.NewClientBuilder().WithScheme(s).WithObjects(admin, pc, pcb, key, cr).Build()
+
+			c := &connector{kube: kube, log: logging.NewNopLogger(), rec: record.NewFakeRecorder(100)}
+			_, err := c.Connect(context.Background(), cr)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("Connect(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
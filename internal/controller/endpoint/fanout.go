@@ -0,0 +1,213 @@
+package endpoint
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients"
+)
+
+// secretRefs returns all the Kubernetes secrets an Endpoint writes its
+// token to: the writeSecretToRef one first, then the additional ones and
+// finally the copies in the namespaces matching the namespace selector,
+// among the allowed ones.
+func secretRefs(ctx context.Context, kube client.Client, cr endpoint, allowed []string) ([]xpv1.SecretReference, error) {
+	spec := cr.GetParameters()
+
+	res := []xpv1.SecretReference{}
+	seen := map[xpv1.SecretReference]bool{}
+
+	add := func(ref xpv1.SecretReference) {
+		if !seen[ref] {
+			seen[ref] = true
+			res = append(res, ref)
+		}
+	}
+
+	if len(spec.WriteSecretToRef.Name) > 0 {
+		add(spec.WriteSecretToRef)
+	}
+
+	for _, ref := range spec.AdditionalSecretRefs {
+		add(ref)
+	}
+
+	if spec.NamespaceSelector == nil || len(spec.WriteSecretToRef.Name) == 0 {
+		return res, nil
+	}
+
+	sel, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid namespace selector")
+	}
+
+	list := &corev1.NamespaceList{}
+	if err := kube.List(ctx, list, client.MatchingLabelsSelector{Selector: sel}); err != nil {
+		return nil, errors.Wrap(err, "cannot list namespaces")
+	}
+
+	for _, ns := range list.Items {
+		if ns.DeletionTimestamp != nil || !clients.IsNamespaceAllowed(allowed, ns.Name) {
+			continue
+		}
+		add(xpv1.SecretReference{Name: spec.WriteSecretToRef.Name, Namespace: ns.Name})
+	}
+
+	return res, nil
+}
+
+// checkNamespaces returns an error if the Endpoint explicitly references a
+// secret, its connection secret included, in a namespace not allowed by its
+// ProviderConfig, or a NamespacedEndpoint a connection secret outside of
+// its namespace.
+func checkNamespaces(cr endpoint, allowed []string) error {
+	spec := cr.GetParameters()
+
+	refs := []xpv1.SecretReference{}
+	if len(spec.WriteSecretToRef.Name) > 0 {
+		refs = append(refs, spec.WriteSecretToRef)
+	}
+	refs = append(refs, spec.AdditionalSecretRefs...)
+	if ref := cr.GetWriteConnectionSecretToReference(); ref != nil {
+		if ns := cr.GetNamespace(); len(ns) > 0 && ref.Namespace != ns {
+			return errors.Errorf(errFmtConnectionSecretNamespace, ref.Namespace)
+		}
+		refs = append(refs, *ref)
+	}
+
+	for _, ref := range refs {
+		if !clients.IsNamespaceAllowed(allowed, ref.Namespace) {
+			return errors.Errorf(errFmtNamespaceNotAllowed, ref.Namespace)
+		}
+	}
+
+	return nil
+}
+
+// isReferenced returns true if the supplied secret is one of the supplied refs.
+func isReferenced(s *corev1.Secret, refs []xpv1.SecretReference) bool {
+	for _, ref := range refs {
+		if ref.Name == s.Name && ref.Namespace == s.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointsForNamespace enqueues all the Endpoints with a namespace selector,
+// so that they can pick up namespaces starting or stopping to match.
+func endpointsForNamespace(kube client.Client) func(client.Object) []reconcile.Request {
+	return func(_ client.Object) []reconcile.Request {
+		list := &endpointsv1alpha1.EndpointList{}
+		if err := kube.List(context.Background(), list); err != nil {
+			return nil
+		}
+
+		res := []reconcile.Request{}
+		for _, el := range list.Items {
+			if el.Spec.ForProvider.NamespaceSelector == nil {
+				continue
+			}
+			res = append(res, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: el.Name},
+			})
+		}
+		return res
+	}
+}
+
+// endpointForSecret enqueues the Endpoint, or NamespacedEndpoint, owning
+// the supplied secret, so that a deleted or edited secret is restored right away.
+func endpointForSecret(o client.Object) []reconcile.Request {
+	key, ok := clients.EndpointOf(o)
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: key}}
+}
+
+// endpointsForProviderConfig enqueues all the endpoints using the supplied
+// ProviderConfig, so that they pick up server or credentials changes.
+func endpointsForProviderConfig(kube client.Client, list lister) func(client.Object) []reconcile.Request {
+	return func(o client.Object) []reconcile.Request {
+		return endpointsUsing(kube, list, o.GetName())
+	}
+}
+
+// endpointsForServer enqueues all the endpoints using a ProviderConfig
+// whose server url depends on the supplied Service or ConfigMap.
+func endpointsForServer(kube client.Client, list lister) func(client.Object) []reconcile.Request {
+	return func(o client.Object) []reconcile.Request {
+		return endpointsUsing(kube, list, clients.ProviderConfigsForServer(context.Background(), kube, o)...)
+	}
+}
+
+// endpointsUsing returns the requests for all the endpoints using one of
+// the named ProviderConfigs.
+func endpointsUsing(kube client.Client, list lister, names ...string) []reconcile.Request {
+	if len(names) == 0 {
+		return nil
+	}
+
+	items, err := list(context.Background(), kube)
+	if err != nil {
+		return nil
+	}
+
+	res := []reconcile.Request{}
+	for _, el := range items {
+		if contains(names, el.providerConfig) {
+			res = append(res, el.request())
+		}
+	}
+	return res
+}
+
+// A lister returns all the endpoints of a kind.
+type lister func(ctx context.Context, kube client.Client) ([]listed, error)
+
+// listed is an endpoint along with the name of the ProviderConfig it uses.
+type listed struct {
+	endpoint
+	providerConfig string
+}
+
+func (l listed) request() reconcile.Request {
+	return reconcile.Request{
+		NamespacedName: types.NamespacedName{Namespace: l.GetNamespace(), Name: l.GetName()},
+	}
+}
+
+// listEndpoints is the lister of the Endpoints.
+func listEndpoints(ctx context.Context, kube client.Client) ([]listed, error) {
+	list := &endpointsv1alpha1.EndpointList{}
+	if err := kube.List(ctx, list); err != nil {
+		return nil, err
+	}
+
+	res := make([]listed, 0, len(list.Items))
+	for i := range list.Items {
+		el := &list.Items[i]
+		res = append(res, listed{endpoint: el, providerConfig: clients.ProviderConfigName(el)})
+	}
+	return res, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, el := range list {
+		if el == s {
+			return true
+		}
+	}
+	return false
+}
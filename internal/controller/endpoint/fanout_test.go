@@ -0,0 +1,85 @@
+package endpoint
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+)
+
+func TestCheckNamespaces(t *testing.T) {
+	allowed := []string{"argocd", "team-*"}
+
+	cases := map[string]struct {
+		additional []xpv1.SecretReference
+		connection *xpv1.SecretReference
+		allowed    []string
+		wantErr    bool
+	}{
+		"Allowed": {
+			additional: []xpv1.SecretReference{{Name: secretName, Namespace: "team-a"}},
+			connection: &xpv1.SecretReference{Name: "dashboard-connection", Namespace: "team-b"},
+			allowed:    allowed,
+		},
+		"NoRestrictions": {
+			additional: []xpv1.SecretReference{{Name: secretName, Namespace: "kube-system"}},
+			connection: &xpv1.SecretReference{Name: "dashboard-connection", Namespace: "kube-system"},
+		},
+		"AdditionalSecretNotAllowed": {
+			additional: []xpv1.SecretReference{{Name: secretName, Namespace: "kube-system"}},
+			allowed:    allowed,
+			wantErr:    true,
+		},
+		"ConnectionSecretNotAllowed": {
+			connection: &xpv1.SecretReference{Name: "dashboard-connection", Namespace: "kube-system"},
+			allowed:    allowed,
+			wantErr:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := newTestEndpoint()
+			cr.Spec.ForProvider.AdditionalSecretRefs = tc.additional
+			cr.Spec.WriteConnectionSecretToReference = tc.connection
+
+			err := checkNamespaces(cr, tc.allowed)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("checkNamespaces(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckNamespacesNamespaced(t *testing.T) {
+	cases := map[string]struct {
+		connection *xpv1.SecretReference
+		wantErr    bool
+	}{
+		"NoConnectionSecret": {},
+		"OwnNamespace": {
+			connection: &xpv1.SecretReference{Name: "dashboard-connection", Namespace: "team-a"},
+		},
+		"OtherNamespace": {
+			connection: &xpv1.SecretReference{Name: "dashboard-connection", Namespace: "team-b"},
+			wantErr:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &endpointsv1alpha1.NamespacedEndpoint{ObjectMeta: metav1.ObjectMeta{Name: "dashboard", Namespace: "team-a"}}
+			cr.Spec.ForProvider.Account = "krateo-dashboard"
+			cr.Spec.ForProvider.WriteSecretToRef = xpv1.LocalSecretReference{Name: secretName}
+			cr.Spec.WriteConnectionSecretToReference = tc.connection
+
+			err := checkNamespaces(cr, nil)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("checkNamespaces(...): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package endpoint
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/connection"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/features"
+)
+
+// SetupNamespaced adds a controller that reconciles NamespacedEndpoint
+// managed resources.
+func SetupNamespaced(mgr ctrl.Manager, o controller.Options) error {
+	name := managed.ControllerName(endpointsv1alpha1.NamespacedEndpointGroupKind)
+
+	log := o.Logger.WithValues("controller", name)
+
+	recorder := mgr.GetEventRecorderFor(name)
+
+	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
+	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
+		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), v1alpha1.StoreConfigGroupVersionKind))
+	}
+
+	r := managed.NewReconciler(mgr,
+		resource.ManagedKind(endpointsv1alpha1.NamespacedEndpointGroupVersionKind),
+		managed.WithExternalConnecter(&connector{
+			kube: mgr.GetClient(),
+			log:  log,
+			rec:  recorder,
+		}),
+		managed.WithInitializers(
+			managed.NewNameAsExternalName(mgr.GetClient()),
+			&providerConfigSelector{kube: mgr.GetClient()}),
+		managed.WithCriticalAnnotationUpdater(&observationKeeper{kube: mgr.GetClient()}),
+		managed.WithFinalizer(newUsageReleaser(mgr.GetClient())),
+		managed.WithConnectionPublishers(cps...),
+		managed.WithLogger(log),
+		managed.WithRecorder(event.NewAPIRecorder(recorder)))
+
+	// only the ConfigMaps and Services referenced by a ProviderConfig are of interest
+	refs := clients.NewReferenceIndex()
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&endpointsv1alpha1.NamespacedEndpoint{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(endpointForSecret),
+			builder.WithPredicates(predicate.NewPredicateFuncs(isNamespacedEndpointSecret))).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(endpointsForAccountsConfigMap(mgr.GetClient(), listNamespacedEndpoints)),
+			builder.WithPredicates(refs.Referenced())).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(endpointsForServer(mgr.GetClient(), listNamespacedEndpoints)),
+			builder.WithPredicates(refs.Referenced())).
+		Watches(&source.Kind{Type: &corev1.Service{}}, handler.EnqueueRequestsFromMapFunc(endpointsForServer(mgr.GetClient(), listNamespacedEndpoints)),
+			builder.WithPredicates(refs.Referenced())).
+		Watches(&source.Kind{Type: &v1alpha1.ProviderConfig{}}, handler.EnqueueRequestsFromMapFunc(endpointsForProviderConfig(mgr.GetClient(), listNamespacedEndpoints)),
+			builder.WithPredicates(refs.Track(), predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &v1alpha1.ProviderConfigBinding{}}, handler.EnqueueRequestsFromMapFunc(endpointsForBinding(mgr.GetClient())),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// isNamespacedEndpointSecret filters the secrets written by this provider
+// for a NamespacedEndpoint.
+func isNamespacedEndpointSecret(o client.Object) bool {
+	key, ok := clients.EndpointOf(o)
+	return ok && len(key.Namespace) > 0
+}
+
+// listNamespacedEndpoints is the lister of the NamespacedEndpoints; their
+// ProviderConfig is the one of the ProviderConfigBinding they reference.
+func listNamespacedEndpoints(ctx context.Context, kube client.Client) ([]listed, error) {
+	pcbs := &v1alpha1.ProviderConfigBindingList{}
+	if err := kube.List(ctx, pcbs); err != nil {
+		return nil, err
+	}
+
+	bound := make(map[types.NamespacedName]string, len(pcbs.Items))
+	for _, el := range pcbs.Items {
+		bound[types.NamespacedName{Namespace: el.Namespace, Name: el.Name}] = el.Spec.ProviderConfigRef.Name
+	}
+
+	list := &endpointsv1alpha1.NamespacedEndpointList{}
+	if err := kube.List(ctx, list); err != nil {
+		return nil, err
+	}
+
+	res := make([]listed, 0, len(list.Items))
+	for i := range list.Items {
+		el := &list.Items[i]
+		if pc, ok := bound[types.NamespacedName{Namespace: el.Namespace, Name: clients.ProviderConfigName(el)}]; ok {
+			res = append(res, listed{endpoint: el, providerConfig: pc})
+		}
+	}
+	return res, nil
+}
+
+// endpointsForBinding enqueues the NamespacedEndpoints referencing the
+// supplied ProviderConfigBinding, so that they pick up its changes.
+func endpointsForBinding(kube client.Client) func(client.Object) []reconcile.Request {
+	return func(o client.Object) []reconcile.Request {
+		list := &endpointsv1alpha1.NamespacedEndpointList{}
+		if err := kube.List(context.Background(), list, client.InNamespace(o.GetNamespace())); err != nil {
+			return nil
+		}
+
+		res := []reconcile.Request{}
+		for _, el := range list.Items {
+			if clients.ProviderConfigName(&el) == o.GetName() {
+				res = append(res, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: el.Namespace, Name: el.Name},
+				})
+			}
+		}
+		return res
+	}
+}
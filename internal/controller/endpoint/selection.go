@@ -0,0 +1,48 @@
+package endpoint
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients"
+)
+
+const (
+	errSelectProviderConfig    = "cannot select ProviderConfig"
+	errUpdateProviderConfigRef = "cannot update providerConfigRef"
+)
+
+// providerConfigSelector is a managed.Initializer setting the
+// providerConfigRef of the endpoints with a providerConfigSelector, so
+// that the usage tracking and the watches keep working on references.
+type providerConfigSelector struct {
+	kube client.Client
+}
+
+// Initialize resolves the providerConfigSelector of the supplied endpoint.
+func (s *providerConfigSelector) Initialize(ctx context.Context, mg resource.Managed) error {
+	cr, ok := mg.(endpoint)
+	if !ok {
+		return errors.New(errNotEndpoint)
+	}
+
+	sel := cr.GetProviderConfigSelector()
+	if sel == nil || meta.WasDeleted(cr) {
+		return nil
+	}
+
+	changed, err := clients.ResolveProviderConfigReference(ctx, s.kube, cr, sel)
+	if err != nil {
+		return errors.Wrap(err, errSelectProviderConfig)
+	}
+	if !changed {
+		return nil
+	}
+
+	return errors.Wrap(s.kube.Update(ctx, cr), errUpdateProviderConfigRef)
+}
@@ -0,0 +1,44 @@
+package endpoint
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients"
+)
+
+// usageReleaser is a resource.Finalizer releasing the ProviderConfigUsage
+// of a NamespacedEndpoint right before removing its finalizer. That is the
+// last step of both deletion paths: Delete is skipped altogether with
+// deletionPolicy Orphan.
+type usageReleaser struct {
+	resource.Finalizer
+	kube client.Client
+}
+
+func newUsageReleaser(kube client.Client) *usageReleaser {
+	return &usageReleaser{
+		Finalizer: resource.NewAPIFinalizer(kube, managed.FinalizerName),
+		kube:      kube,
+	}
+}
+
+// RemoveFinalizer releases the ProviderConfigUsage of the supplied
+// endpoint, then removes its finalizer.
+func (f *usageReleaser) RemoveFinalizer(ctx context.Context, obj resource.Object) error {
+	mg, ok := obj.(resource.Managed)
+	if !ok {
+		return errors.New(errNotEndpoint)
+	}
+
+	if err := clients.ReleaseProviderConfigUsage(ctx, f.kube, mg); err != nil {
+		return err
+	}
+
+	return f.Finalizer.RemoveFinalizer(ctx, obj)
+}
@@ -0,0 +1,67 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis"
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+)
+
+func TestUsageReleaserRemoveFinalizer(t *testing.T) {
+	cases := map[string]struct {
+		usage bool
+	}{
+		"InUse": {
+			usage: true,
+		},
+		"AlreadyReleased": {},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			s := runtime.NewScheme()
+			if err := corev1.AddToScheme(s); err != nil {
+				t.Fatal(err)
+			}
+			if err := apis.AddToScheme(s); err != nil {
+				t.Fatal(err)
+			}
+
+			cr := &endpointsv1alpha1.NamespacedEndpoint{ObjectMeta: metav1.ObjectMeta{Name: "dashboard", Namespace: "team-a", UID: "1234"}}
+			meta.AddFinalizer(cr, managed.FinalizerName)
+
+			objs := []client.Object{cr}
+			if tc.usage {
+				pcu := &v1alpha1.ProviderConfigUsage{ObjectMeta: metav1.ObjectMeta{Name: string(cr.GetUID())}}
+				objs = append(objs, pcu)
+			}
+			kube := fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build()
+
+			if err := newUsageReleaser(kube).RemoveFinalizer(ctx, cr); err != nil {
+				t.Fatalf("RemoveFinalizer(...): %v", err)
+			}
+
+			err := kube.Get(ctx, client.ObjectKey{Name: string(cr.GetUID())}, &v1alpha1.ProviderConfigUsage{})
+			if !apierrors.IsNotFound(err) {
+				t.Errorf("RemoveFinalizer(...): want the ProviderConfigUsage released, got %v", err)
+			}
+			if meta.FinalizerExists(cr, managed.FinalizerName) {
+				t.Errorf("RemoveFinalizer(...): want the finalizer removed")
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+//go:build integration
+// +build integration
+
+package controller_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/feature"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis"
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/argocdtest"
+	argocdcontroller "github.com/krateoplatformops/provider-argocd-endpoint/internal/controller"
+)
+
+const (
+	timeout  = 30 * time.Second
+	interval = 250 * time.Millisecond
+
+	// creationGracePeriod is the time the managed reconciler waits for a
+	// created token to be observed before issuing a new one.
+	creationGracePeriod = 30 * time.Second
+)
+
+// startManager starts an API server with the provider CRDs and a manager
+// running the provider controllers; it returns a client of the API server.
+func startManager(t *testing.T) client.Client {
+	t.Helper()
+
+	// the suite is only built on request: fail rather than silently skip
+	if len(os.Getenv("KUBEBUILDER_ASSETS")) == 0 {
+		t.Fatal("KUBEBUILDER_ASSETS is not set: run make test.integration, see https://book.kubebuilder.io/reference/envtest.html")
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "package", "crds")},
+		ErrorIfCRDPathMissing: true,
+	}
+
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatalf("cannot start envtest: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := env.Stop(); err != nil {
+			t.Errorf("cannot stop envtest: %v", err)
+		}
+	})
+
+	s := runtime.NewScheme()
+	if err := corev1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{Scheme: s, MetricsBindAddress: "0"})
+	if err != nil {
+		t.Fatalf("cannot create manager: %v", err)
+	}
+
+	o := controller.Options{
+		Logger:                  logging.NewNopLogger(),
+		MaxConcurrentReconciles: 1,
+		PollInterval:            time.Second,
+		GlobalRateLimiter:       ratelimiter.NewGlobal(10),
+		Features:                &feature.Flags{},
+	}
+	if err := argocdcontroller.Setup(mgr, o); err != nil {
+		t.Fatalf("cannot setup controllers: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := mgr.Start(ctx); err != nil {
+			t.Errorf("cannot start manager: %v", err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	kube, err := client.New(cfg, client.Options{Scheme: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kube
+}
+
+// eventually polls the supplied condition until it is true or the timeout expires.
+func eventually(t *testing.T, what string, cond func() (bool, error)) {
+	t.Helper()
+	eventuallyWithin(t, what, timeout, cond)
+}
+
+// eventuallyWithin polls the supplied condition until it is true or the
+// supplied timeout expires.
+func eventuallyWithin(t *testing.T, what string, timeout time.Duration, cond func() (bool, error)) {
+	t.Helper()
+
+	if err := wait.PollImmediate(interval, timeout, cond); err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+}
+
+func isGone(kube client.Client, key types.NamespacedName, o client.Object) func() (bool, error) {
+	return func() (bool, error) {
+		err := kube.Get(context.Background(), key, o)
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+}
+
+// createProviderConfig creates the default ProviderConfig of the supplied
+// ArgoCD stand-in, with its admin secret.
+func createProviderConfig(t *testing.T, kube client.Client, srv *argocdtest.Server) *v1alpha1.ProviderConfig {
+	t.Helper()
+	ctx := context.Background()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "argocd"}}
+	if err := kube.Create(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "argocd-initial-admin-secret", Namespace: "argocd"}}
+	admin.Data = map[string][]byte{corev1.BasicAuthPasswordKey: []byte(argocdtest.AdminPassword)}
+	if err := kube.Create(ctx, admin); err != nil {
+		t.Fatal(err)
+	}
+
+	pc := &v1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	pc.Spec.ServerUrl = srv.URL
+	pc.Spec.Credentials = &v1alpha1.ProviderCredentials{
+		Source: xpv1.CredentialsSourceSecret,
+		CommonCredentialSelectors: xpv1.CommonCredentialSelectors{
+			SecretRef: &xpv1.SecretKeySelector{
+				SecretReference: xpv1.SecretReference{Name: admin.Name, Namespace: admin.Namespace},
+				Key:             corev1.BasicAuthPasswordKey,
+			},
+		},
+	}
+	if err := kube.Create(ctx, pc); err != nil {
+		t.Fatal(err)
+	}
+	return pc
+}
+
+func TestEndpointLifecycle(t *testing.T) {
+	kube := startManager(t)
+	ctx := context.Background()
+
+	srv := argocdtest.NewServer()
+	defer srv.Close()
+
+	pc := createProviderConfig(t, kube, srv)
+
+	cr := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: "dashboard"}}
+	cr.Spec.ProviderConfigReference = &xpv1.Reference{Name: pc.Name}
+	cr.Spec.ForProvider.Account = argocdtest.Account
+	cr.Spec.ForProvider.WriteSecretToRef = xpv1.SecretReference{Name: "dashboard-endpoint", Namespace: "argocd"}
+	if err := kube.Create(ctx, cr); err != nil {
+		t.Fatal(err)
+	}
+
+	crKey := types.NamespacedName{Name: cr.Name}
+	secretKey := types.NamespacedName{Name: "dashboard-endpoint", Namespace: "argocd"}
+
+	// Create and observe: the token is minted, written to the secret and
+	// the Endpoint becomes ready.
+	eventually(t, "Endpoint is ready", func() (bool, error) {
+		if err := kube.Get(ctx, crKey, cr); err != nil {
+			return false, err
+		}
+		return cr.GetCondition(xpv1.TypeReady).Status == corev1.ConditionTrue &&
+			cr.GetCondition(xpv1.TypeSynced).Status == corev1.ConditionTrue, nil
+	})
+
+	if len(cr.Status.AtProvider.ID) == 0 {
+		t.Errorf("Endpoint status: want token id, got none")
+	}
+	if got := len(srv.Fake.Tokens(argocdtest.Account)); got != 1 {
+		t.Errorf("ArgoCD tokens: want 1, got %d", got)
+	}
+
+	sec := &corev1.Secret{}
+	if err := kube.Get(ctx, secretKey, sec); err != nil {
+		t.Fatalf("endpoint secret: %v", err)
+	}
+	if len(sec.Data["bearer"]) == 0 || string(sec.Data["target"]) != srv.URL {
+		t.Errorf("endpoint secret: unexpected data %v", sec.Data)
+	}
+
+	// Secret lifecycle: a deleted secret is written again, once the token
+	// is no longer within its creation grace period.
+	if err := kube.Delete(ctx, sec); err != nil {
+		t.Fatal(err)
+	}
+	eventuallyWithin(t, "endpoint secret is restored", creationGracePeriod+timeout, func() (bool, error) {
+		err := kube.Get(ctx, secretKey, &corev1.Secret{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+
+	// ProviderConfigUsage tracking.
+	usages := &v1alpha1.ProviderConfigUsageList{}
+	eventually(t, "ProviderConfigUsage is tracked", func() (bool, error) {
+		if err := kube.List(ctx, usages, client.MatchingLabels{xpv1.LabelKeyProviderName: pc.Name}); err != nil {
+			return false, err
+		}
+		return len(usages.Items) == 1 && usages.Items[0].ResourceReference.Name == cr.Name, nil
+	})
+
+	// Deletion of the ProviderConfig is blocked while it is in use.
+	if err := kube.Delete(ctx, pc); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "ProviderConfig deletion is blocked", func() (bool, error) {
+		if err := kube.Get(ctx, types.NamespacedName{Name: pc.Name}, pc); err != nil {
+			return false, err
+		}
+		return pc.GetDeletionTimestamp() != nil && len(pc.GetFinalizers()) > 0, nil
+	})
+	if err := wait.PollImmediate(interval, 2*time.Second, isGone(kube, types.NamespacedName{Name: pc.Name}, &v1alpha1.ProviderConfig{})); err == nil {
+		t.Fatalf("ProviderConfig in use: want it to exist, got deleted")
+	}
+
+	// Delete: the token is revoked, the secret removed and the Endpoint
+	// finalizer released. The token restored above may still be within its
+	// creation grace period, which holds the finalizer until the first
+	// requeue after it expires.
+	if err := kube.Delete(ctx, cr); err != nil {
+		t.Fatal(err)
+	}
+	eventuallyWithin(t, "Endpoint is deleted", 2*creationGracePeriod+timeout, isGone(kube, crKey, &endpointsv1alpha1.Endpoint{}))
+	eventually(t, "endpoint secret is deleted", isGone(kube, secretKey, &corev1.Secret{}))
+
+	if got := len(srv.Fake.Tokens(argocdtest.Account)); got != 0 {
+		t.Errorf("ArgoCD tokens: want 0 after delete, got %d", got)
+	}
+
+	// envtest runs no garbage collector: remove the usages owned by the
+	// deleted Endpoint as the API server would.
+	if err := kube.DeleteAllOf(ctx, &v1alpha1.ProviderConfigUsage{}, client.MatchingLabels{xpv1.LabelKeyProviderName: pc.Name}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "ProviderConfig is deleted", isGone(kube, types.NamespacedName{Name: pc.Name}, &v1alpha1.ProviderConfig{}))
+}
+
+func TestNamespacedEndpointOrphan(t *testing.T) {
+	kube := startManager(t)
+	ctx := context.Background()
+
+	srv := argocdtest.NewServer()
+	defer srv.Close()
+
+	pc := createProviderConfig(t, kube, srv)
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	if err := kube.Create(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+
+	pcb := &v1alpha1.ProviderConfigBinding{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: ns.Name}}
+	pcb.Spec.ProviderConfigRef = xpv1.Reference{Name: pc.Name}
+	if err := kube.Create(ctx, pcb); err != nil {
+		t.Fatal(err)
+	}
+
+	cr := &endpointsv1alpha1.NamespacedEndpoint{ObjectMeta: metav1.ObjectMeta{Name: "dashboard", Namespace: ns.Name}}
+	cr.Spec.DeletionPolicy = xpv1.DeletionOrphan
+	cr.Spec.ProviderConfigReference = &xpv1.Reference{Name: pcb.Name}
+	cr.Spec.ForProvider.Account = argocdtest.Account
+	cr.Spec.ForProvider.WriteSecretToRef = xpv1.LocalSecretReference{Name: "dashboard-endpoint"}
+	if err := kube.Create(ctx, cr); err != nil {
+		t.Fatal(err)
+	}
+
+	crKey := types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}
+
+	eventually(t, "NamespacedEndpoint is ready", func() (bool, error) {
+		if err := kube.Get(ctx, crKey, cr); err != nil {
+			return false, err
+		}
+		return cr.GetCondition(xpv1.TypeReady).Status == corev1.ConditionTrue, nil
+	})
+
+	usages := &v1alpha1.ProviderConfigUsageList{}
+	eventually(t, "ProviderConfigUsage is tracked", func() (bool, error) {
+		if err := kube.List(ctx, usages, client.MatchingLabels{xpv1.LabelKeyProviderName: pc.Name}); err != nil {
+			return false, err
+		}
+		return len(usages.Items) == 1, nil
+	})
+	// envtest runs no garbage collector, which deletes the cluster scoped
+	// objects owned by a namespaced one.
+	if c := metav1.GetControllerOf(&usages.Items[0]); c == nil || c.UID != pc.UID {
+		t.Errorf("ProviderConfigUsage: want controlled by the ProviderConfig, got %v", usages.Items[0].GetOwnerReferences())
+	}
+
+	// Orphan: the token is left alone, the usage is released all the same
+	// and the ProviderConfig can be deleted.
+	if err := kube.Delete(ctx, cr); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "NamespacedEndpoint is deleted", isGone(kube, crKey, &endpointsv1alpha1.NamespacedEndpoint{}))
+
+	if got := len(srv.Fake.Tokens(argocdtest.Account)); got != 1 {
+		t.Errorf("ArgoCD tokens: want 1 orphaned token, got %d", got)
+	}
+
+	if err := kube.Delete(ctx, pc); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "ProviderConfig is deleted", isGone(kube, types.NamespacedName{Name: pc.Name}, &v1alpha1.ProviderConfig{}))
+}
@@ -0,0 +1,205 @@
+package fakeargocd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// gRPC-web wire format, see:
+// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md
+const (
+	grpcWebContentType = "application/grpc-web+proto"
+
+	grpcWebFrameData    byte = 0x00
+	grpcWebFrameTrailer byte = 0x80
+)
+
+// ArgoCD gRPC methods served over gRPC-web, see:
+// https://github.com/argoproj/argo-cd/tree/master/server
+const (
+	MethodSessionCreate      = "session.SessionService/Create"
+	MethodVersion            = "version.VersionService/Version"
+	MethodAccountGet         = "account.AccountService/GetAccount"
+	MethodAccountCreateToken = "account.AccountService/CreateToken"
+	MethodAccountDeleteToken = "account.AccountService/DeleteToken"
+)
+
+// isGRPCWeb returns true if the supplied request is a gRPC-web call.
+func isGRPCWeb(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc-web")
+}
+
+// serveGRPCWeb serves the supplied unary gRPC method.
+func (s *Server) serveGRPCWeb(w http.ResponseWriter, r *http.Request, method string) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeGRPCWeb(w, nil, errorf(codeInvalidArgument, "%s", err.Error()))
+		return
+	}
+	if len(body) < 5 || body[0] != grpcWebFrameData || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+		writeGRPCWeb(w, nil, errorf(codeInvalidArgument, "invalid grpc-web frame"))
+		return
+	}
+
+	req, err := parseMessage(body[5:])
+	if err != nil {
+		writeGRPCWeb(w, nil, errorf(codeInvalidArgument, "%s", err.Error()))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if method != MethodSessionCreate && method != MethodVersion && !s.authenticated(r) {
+		writeGRPCWeb(w, nil, errUnauthenticated)
+		return
+	}
+
+	var (
+		res  []byte
+		aerr *apiError
+	)
+	switch method {
+	case MethodVersion:
+		// version.VersionMessage{Version = 1}
+		res = appendString(nil, 1, s.cfg.Version)
+	case MethodSessionCreate:
+		// session.SessionCreateRequest{username = 1, password = 2}
+		var token string
+		token, aerr = s.createSession(req.str(1), req.str(2))
+		// session.SessionResponse{token = 1}
+		res = appendString(nil, 1, token)
+	case MethodAccountGet:
+		// account.GetAccountRequest{name = 1}
+		if _, ok := s.accounts[req.str(1)]; !ok {
+			aerr = errAccountNotFound(req.str(1))
+			break
+		}
+		res = s.accountMessage(req.str(1))
+	case MethodAccountCreateToken:
+		// account.CreateTokenRequest{name = 1, expiresIn = 2, id = 3}
+		var token string
+		token, aerr = s.createToken(req.str(1), req.str(3), int64(req.varint(2)))
+		// account.CreateTokenResponse{token = 1}
+		res = appendString(nil, 1, token)
+	case MethodAccountDeleteToken:
+		// account.DeleteTokenRequest{name = 1, id = 2}
+		aerr = s.deleteToken(req.str(1), req.str(2))
+	default:
+		aerr = errorf(codeUnimplemented, "unknown method %s", method)
+	}
+
+	writeGRPCWeb(w, res, aerr)
+}
+
+// accountMessage returns the account.Account message of the named account:
+// {name = 1, enabled = 2, capabilities = 3, tokens = 4}, with
+// account.Token{id = 1, issuedAt = 2, expiresAt = 3}.
+func (s *Server) accountMessage(name string) []byte {
+	a := s.accounts[name]
+
+	var res []byte
+	res = appendString(res, 1, a.Name)
+	if a.Enabled {
+		res = protowire.AppendTag(res, 2, protowire.VarintType)
+		res = protowire.AppendVarint(res, 1)
+	}
+	for _, c := range a.Capabilities {
+		res = appendString(res, 3, c)
+	}
+	for _, tok := range s.tokens[name] {
+		var el []byte
+		el = appendString(el, 1, tok.ID)
+		el = protowire.AppendTag(el, 2, protowire.VarintType)
+		el = protowire.AppendVarint(el, uint64(tok.IssuedAt))
+		if tok.ExpiresAt > 0 {
+			el = protowire.AppendTag(el, 3, protowire.VarintType)
+			el = protowire.AppendVarint(el, uint64(tok.ExpiresAt))
+		}
+		res = protowire.AppendTag(res, 4, protowire.BytesType)
+		res = protowire.AppendBytes(res, el)
+	}
+	return res
+}
+
+// message holds the scalar fields of a protobuf message.
+type message struct {
+	strings map[protowire.Number]string
+	varints map[protowire.Number]uint64
+}
+
+func (m message) str(num protowire.Number) string {
+	return m.strings[num]
+}
+
+func (m message) varint(num protowire.Number) uint64 {
+	return m.varints[num]
+}
+
+func parseMessage(b []byte) (message, error) {
+	res := message{strings: map[protowire.Number]string{}, varints: map[protowire.Number]uint64{}}
+
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return res, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch typ {
+		case protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			res.strings[num] = string(v)
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			res.varints[num] = v
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return res, protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+
+	return res, nil
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// writeGRPCWeb writes the supplied response message, or error, followed by
+// the trailer frame carrying the call status.
+func writeGRPCWeb(w http.ResponseWriter, msg []byte, err *apiError) {
+	w.Header().Set("Content-Type", grpcWebContentType)
+
+	status, message := 0, ""
+	if err != nil {
+		status, message = err.code, err.msg
+	}
+
+	if err == nil {
+		_, _ = w.Write(frame(grpcWebFrameData, msg))
+	}
+	trailer := fmt.Sprintf("grpc-status: %d\r\ngrpc-message: %s\r\n", status, message)
+	_, _ = w.Write(frame(grpcWebFrameTrailer, []byte(trailer)))
+}
+
+func frame(flag byte, payload []byte) []byte {
+	res := make([]byte, 5, 5+len(payload))
+	res[0] = flag
+	binary.BigEndian.PutUint32(res[1:], uint32(len(payload)))
+	return append(res, payload...)
+}
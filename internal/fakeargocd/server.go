@@ -0,0 +1,447 @@
+// Package fakeargocd is an in-memory implementation of the ArgoCD session
+// and account APIs, over REST and gRPC-web, for local development, demos
+// and tests.
+package fakeargocd
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AdminAccount is the built-in admin account.
+	AdminAccount = "admin"
+
+	// CapabilityAPIKey allows an account to have tokens.
+	CapabilityAPIKey = "apiKey"
+	// CapabilityLogin allows an account to log in.
+	CapabilityLogin = "login"
+
+	defaultVersion = "v2.4.7+fake"
+)
+
+// gRPC status codes, as reported by the ArgoCD REST gateway.
+const (
+	codeInvalidArgument    = 3
+	codeNotFound           = 5
+	codeAlreadyExists      = 6
+	codePermissionDenied   = 7
+	codeFailedPrecondition = 9
+	codeUnimplemented      = 12
+	codeUnauthenticated    = 16
+)
+
+// An Account is an ArgoCD local account.
+type Account struct {
+	Name         string
+	Enabled      bool
+	Capabilities []string
+}
+
+// A Token is an ArgoCD account token.
+type Token struct {
+	ID        string
+	IssuedAt  int64
+	ExpiresAt int64
+}
+
+// Config configures a fake ArgoCD server.
+type Config struct {
+	// AdminPassword is the password of the admin account.
+	AdminPassword string
+	// RootPath the API is served under, as with argocd-server --rootpath.
+	RootPath string
+	// Version reported by /api/version.
+	Version string
+	// Accounts besides admin.
+	Accounts []Account
+}
+
+// Server is a fake ArgoCD API server; it implements http.Handler.
+type Server struct {
+	mu       sync.Mutex
+	cfg      Config
+	key      []byte
+	now      func() time.Time
+	accounts map[string]*Account
+	tokens   map[string][]Token
+	sessions map[string]bool
+}
+
+// NewServer returns a fake ArgoCD server with the supplied configuration.
+func NewServer(cfg Config) *Server {
+	if len(cfg.Version) == 0 {
+		cfg.Version = defaultVersion
+	}
+	cfg.RootPath = strings.TrimRight("/"+strings.Trim(cfg.RootPath, "/"), "/")
+
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+
+	s := &Server{
+		cfg:      cfg,
+		key:      key,
+		now:      time.Now,
+		accounts: map[string]*Account{},
+		tokens:   map[string][]Token{},
+		sessions: map[string]bool{},
+	}
+
+	s.accounts[AdminAccount] = &Account{Name: AdminAccount, Enabled: true, Capabilities: []string{CapabilityLogin}}
+	for i := range cfg.Accounts {
+		a := cfg.Accounts[i]
+		s.accounts[a.Name] = &a
+	}
+
+	return s
+}
+
+// SetAccount adds or replaces an account.
+func (s *Server) SetAccount(a Account) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[a.Name] = &a
+}
+
+// RemoveAccount removes an account along with its tokens.
+func (s *Server) RemoveAccount(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.accounts, name)
+	delete(s.tokens, name)
+}
+
+// Tokens returns the tokens of the named account.
+func (s *Server) Tokens(name string) []Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Token{}, s.tokens[name]...)
+}
+
+// An apiError is an ArgoCD API error: a gRPC status code and message.
+type apiError struct {
+	code int
+	msg  string
+}
+
+func (e *apiError) Error() string { return e.msg }
+
+// httpStatus returns the HTTP status the REST gateway answers with.
+func (e *apiError) httpStatus() int {
+	switch e.code {
+	case codeInvalidArgument, codeFailedPrecondition:
+		return http.StatusBadRequest
+	case codeNotFound:
+		return http.StatusNotFound
+	case codeAlreadyExists:
+		return http.StatusConflict
+	case codePermissionDenied:
+		return http.StatusForbidden
+	case codeUnauthenticated:
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+func errorf(code int, format string, args ...interface{}) *apiError {
+	return &apiError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// ServeHTTP serves the ArgoCD REST API subset used by the provider, and
+// the same calls over gRPC-web, as argocd-server does.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, s.cfg.RootPath)
+	if len(p) == len(r.URL.Path) && len(s.cfg.RootPath) > 0 {
+		writeError(w, errorf(codeNotFound, "not found"))
+		return
+	}
+
+	if isGRPCWeb(r) {
+		s.serveGRPCWeb(w, r, strings.Trim(p, "/"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && match(parts, "api", "version"):
+		writeJSON(w, map[string]string{"Version": s.cfg.Version})
+	case r.Method == http.MethodPost && match(parts, "api", "v1", "session"):
+		s.restCreateSession(w, r)
+	case !s.authenticated(r):
+		writeError(w, errUnauthenticated)
+	case r.Method == http.MethodGet && match(parts, "api", "v1", "account"):
+		s.restListAccounts(w)
+	case r.Method == http.MethodGet && match(parts, "api", "v1", "account", "*"):
+		s.restGetAccount(w, parts[3])
+	case r.Method == http.MethodPost && match(parts, "api", "v1", "account", "*", "token"):
+		s.restCreateToken(w, r, parts[3])
+	case r.Method == http.MethodDelete && match(parts, "api", "v1", "account", "*", "token", "*"):
+		s.restDeleteToken(w, parts[3], parts[5])
+	default:
+		writeError(w, errorf(codeNotFound, "not found"))
+	}
+}
+
+var errUnauthenticated = errorf(codeUnauthenticated, "invalid session: token is missing or invalid")
+
+func (s *Server) restCreateSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errorf(codeInvalidArgument, "%s", err.Error()))
+		return
+	}
+
+	token, err := s.createSession(req.Username, req.Password)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]string{"token": token})
+}
+
+func (s *Server) restListAccounts(w http.ResponseWriter) {
+	names := make([]string, 0, len(s.accounts))
+	for name := range s.accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		items = append(items, s.account(name))
+	}
+
+	writeJSON(w, map[string]interface{}{"items": items})
+}
+
+func (s *Server) restGetAccount(w http.ResponseWriter, name string) {
+	if _, ok := s.accounts[name]; !ok {
+		writeError(w, errAccountNotFound(name))
+		return
+	}
+
+	writeJSON(w, s.account(name))
+}
+
+func (s *Server) restCreateToken(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		ID        string      `json:"id"`
+		ExpiresIn json.Number `json:"expiresIn"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, errorf(codeInvalidArgument, "%s", err.Error()))
+			return
+		}
+	}
+
+	var expiresIn int64
+	if len(req.ExpiresIn) > 0 {
+		n, err := req.ExpiresIn.Int64()
+		if err != nil {
+			writeError(w, errorf(codeInvalidArgument, "%s", err.Error()))
+			return
+		}
+		expiresIn = n
+	}
+
+	token, err := s.createToken(name, req.ID, expiresIn)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]string{"token": token})
+}
+
+func (s *Server) restDeleteToken(w http.ResponseWriter, name, id string) {
+	if err := s.deleteToken(name, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]string{})
+}
+
+// createSession logs the admin in and returns the session token.
+func (s *Server) createSession(user, pass string) (string, *apiError) {
+	if user != AdminAccount || pass != s.cfg.AdminPassword {
+		return "", errorf(codeUnauthenticated, "Invalid username or password")
+	}
+
+	token := s.sign(AdminAccount, newID(), 0)
+	s.sessions[token] = true
+
+	return token, nil
+}
+
+func (s *Server) authenticated(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return s.sessions[token]
+}
+
+// createToken issues a token for the named account; the id is generated
+// if empty, the token never expires if expiresIn is not positive.
+func (s *Server) createToken(name, id string, expiresIn int64) (string, *apiError) {
+	a, ok := s.accounts[name]
+	if !ok {
+		return "", errAccountNotFound(name)
+	}
+	if !a.Enabled {
+		return "", errorf(codeFailedPrecondition, "account '%s' is disabled", name)
+	}
+	if !hasCapability(a, CapabilityAPIKey) {
+		return "", errorf(codePermissionDenied, "account '%s' does not have %s capability", name, CapabilityAPIKey)
+	}
+
+	if len(id) == 0 {
+		id = newID()
+	}
+	for _, el := range s.tokens[name] {
+		if el.ID == id {
+			return "", errorf(codeAlreadyExists, "account already has token with id '%s'", id)
+		}
+	}
+
+	now := s.now().UTC()
+	tok := Token{ID: id, IssuedAt: now.Unix()}
+	if expiresIn > 0 {
+		tok.ExpiresAt = now.Add(time.Duration(expiresIn) * time.Second).Unix()
+	}
+	s.tokens[name] = append(s.tokens[name], tok)
+
+	return s.sign(name+":"+CapabilityAPIKey, id, tok.ExpiresAt), nil
+}
+
+// deleteToken revokes the token with the supplied id of the named account.
+func (s *Server) deleteToken(name, id string) *apiError {
+	list := s.tokens[name]
+	for i, el := range list {
+		if el.ID == id {
+			s.tokens[name] = append(list[:i:i], list[i+1:]...)
+			return nil
+		}
+	}
+
+	return errorf(codeNotFound, "account '%s' does not have token with id '%s'", name, id)
+}
+
+func errAccountNotFound(name string) *apiError {
+	return errorf(codeNotFound, "account '%s' does not exist", name)
+}
+
+// account returns the JSON representation of the named account; as the
+// REST gateway does, int64 values are encoded as strings.
+func (s *Server) account(name string) map[string]interface{} {
+	a := s.accounts[name]
+
+	tokens := make([]map[string]string, 0, len(s.tokens[name]))
+	for _, el := range s.tokens[name] {
+		tok := map[string]string{
+			"id":       el.ID,
+			"issuedAt": strconv.FormatInt(el.IssuedAt, 10),
+		}
+		if el.ExpiresAt > 0 {
+			tok["expiresAt"] = strconv.FormatInt(el.ExpiresAt, 10)
+		}
+		tokens = append(tokens, tok)
+	}
+
+	return map[string]interface{}{
+		"name":         a.Name,
+		"enabled":      a.Enabled,
+		"capabilities": a.Capabilities,
+		"tokens":       tokens,
+	}
+}
+
+// sign returns a JWT with the claims ArgoCD uses, signed with HS256.
+func (s *Server) sign(subject, id string, exp int64) string {
+	now := s.now().UTC().Unix()
+
+	claims := map[string]interface{}{
+		"iss": "argocd",
+		"sub": subject,
+		"nbf": now,
+		"iat": now,
+		"jti": id,
+	}
+	if exp > 0 {
+		claims["exp"] = exp
+	}
+
+	enc := base64.RawURLEncoding
+	hdr, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	unsigned := enc.EncodeToString(hdr) + "." + enc.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(unsigned))
+
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func match(parts []string, want ...string) bool {
+	if len(parts) != len(want) {
+		return false
+	}
+	for i := range want {
+		if want[i] != "*" && want[i] != parts[i] {
+			return false
+		}
+		if want[i] == "*" && len(parts[i]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func hasCapability(a *Account, c string) bool {
+	for _, el := range a.Capabilities {
+		if el == c {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error as the ArgoCD REST gateway does.
+func writeError(w http.ResponseWriter, err *apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.httpStatus())
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   err.msg,
+		"code":    err.code,
+		"message": err.msg,
+	})
+}
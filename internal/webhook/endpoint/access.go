@@ -0,0 +1,169 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients"
+)
+
+// validateAccess checks that the requesting user may create secrets in the
+// namespaces the Endpoint writes to, and that its ProviderConfig allows them:
+// the provider writes secrets with its own cluster wide permissions.
+// On update only the newly referenced namespaces are checked.
+func (v *validator) validateAccess(ctx context.Context, req admission.Request, cr, old endpoint, pc *v1alpha1.ProviderConfig) (field.ErrorList, error) {
+	path := field.NewPath("spec", "forProvider")
+
+	errs := field.ErrorList{}
+
+	var allowed []string
+	if pc != nil {
+		allowed = pc.Spec.AllowedNamespaces
+	}
+
+	for _, t := range targetNamespaces(path, cr, old) {
+		if !clients.IsNamespaceAllowed(allowed, t.namespace) {
+			errs = append(errs, field.Forbidden(t.path, fmt.Sprintf("ProviderConfig does not allow writing secrets to namespace %s", t.namespace)))
+			continue
+		}
+
+		ok, err := v.canCreateSecrets(ctx, req, t.namespace)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			errs = append(errs, field.Forbidden(t.path, fmt.Sprintf("user %s cannot create secrets in namespace %s", req.UserInfo.Username, t.namespace)))
+		}
+	}
+
+	// The namespace selector can match any namespace, now or later on.
+	sel := cr.GetParameters().NamespaceSelector
+	if sel != nil && (old == nil || !equality.Semantic.DeepEqual(sel, old.GetParameters().NamespaceSelector)) {
+		ok, err := v.canCreateSecrets(ctx, req, "")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			errs = append(errs, field.Forbidden(path.Child("namespaceSelector"), fmt.Sprintf("user %s cannot create secrets in all namespaces", req.UserInfo.Username)))
+		}
+	}
+
+	return errs, nil
+}
+
+// A target is a namespace the Endpoint writes a secret to.
+type target struct {
+	path      *field.Path
+	namespace string
+}
+
+// targetNamespaces returns the namespaces of the secrets explicitly
+// referenced by the Endpoint, its connection secret included, and not by
+// its old version, if any.
+func targetNamespaces(path *field.Path, cr, old endpoint) []target {
+	seen := map[string]bool{}
+	if old != nil {
+		for _, ref := range explicitRefs(old) {
+			seen[ref.Namespace] = true
+		}
+		if ref := old.GetWriteConnectionSecretToReference(); ref != nil {
+			seen[ref.Namespace] = true
+		}
+	}
+
+	res := []target{}
+	add := func(p *field.Path, ns string) {
+		if !seen[ns] {
+			seen[ns] = true
+			res = append(res, target{path: p.Child("namespace"), namespace: ns})
+		}
+	}
+
+	spec := cr.GetParameters()
+	if len(spec.WriteSecretToRef.Name) > 0 {
+		add(path.Child("writeSecretToRef"), spec.WriteSecretToRef.Namespace)
+	}
+	for i, ref := range spec.AdditionalSecretRefs {
+		add(path.Child("additionalSecretRefs").Index(i), ref.Namespace)
+	}
+	// the connection secret is written with the provider permissions too
+	if ref := cr.GetWriteConnectionSecretToReference(); ref != nil {
+		add(field.NewPath("spec", "writeConnectionSecretToRef"), ref.Namespace)
+	}
+
+	return res
+}
+
+// explicitRefs returns the secrets explicitly referenced by the Endpoint.
+func explicitRefs(cr endpoint) []xpv1.SecretReference {
+	spec := cr.GetParameters()
+
+	res := []xpv1.SecretReference{}
+	if len(spec.WriteSecretToRef.Name) > 0 {
+		res = append(res, spec.WriteSecretToRef)
+	}
+	return append(res, spec.AdditionalSecretRefs...)
+}
+
+// providerConfig returns the ProviderConfig of the Endpoint, and its
+// ProviderConfigBinding for a NamespacedEndpoint; nil if they do not
+// exist (yet). The providerConfigSelector, if any, is resolved as the
+// controller does.
+func (v *validator) providerConfig(ctx context.Context, cr endpoint) (*v1alpha1.ProviderConfig, *v1alpha1.ProviderConfigBinding, error) {
+	if sel := cr.GetProviderConfigSelector(); sel != nil {
+		name, err := clients.SelectProviderConfig(ctx, v.kube, cr, sel)
+		if err != nil {
+			// the controller reports that no ProviderConfig matches
+			return nil, nil, nil
+		}
+
+		cr = cr.DeepCopyObject().(endpoint)
+		cr.SetProviderConfigReference(&xpv1.Reference{Name: name})
+	}
+
+	pc, pcb, err := clients.GetProviderConfig(ctx, v.kube, cr)
+	if apierrors.IsNotFound(errors.Cause(err)) {
+		return nil, nil, nil
+	}
+	return pc, pcb, err
+}
+
+// canCreateSecrets returns true if the user of the admission request may
+// create secrets in the supplied namespace; in all namespaces if empty.
+func (v *validator) canCreateSecrets(ctx context.Context, req admission.Request, ns string) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(req.UserInfo.Extra))
+	for k, v := range req.UserInfo.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   req.UserInfo.Username,
+			Groups: req.UserInfo.Groups,
+			UID:    req.UserInfo.UID,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: ns,
+				Verb:      "create",
+				Version:   "v1",
+				Resource:  "secrets",
+			},
+		},
+	}
+
+	if err := v.kube.Create(ctx, sar); err != nil {
+		return false, err
+	}
+
+	return sar.Status.Allowed, nil
+}
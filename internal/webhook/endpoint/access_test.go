@@ -0,0 +1,260 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+)
+
+// sarClient answers the SubjectAccessReviews allowing the creation of
+// secrets in the supplied namespaces, "" meaning all of them, and records
+// the reviewed namespaces.
+type sarClient struct {
+	client.Client
+
+	allowed  map[string]bool
+	err      error
+	reviewed []string
+}
+
+func (c *sarClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	sar, ok := obj.(*authorizationv1.SubjectAccessReview)
+	if !ok {
+		return c.Client.Create(ctx, obj, opts...)
+	}
+	if c.err != nil {
+		return c.err
+	}
+
+	ns := sar.Spec.ResourceAttributes.Namespace
+	c.reviewed = append(c.reviewed, ns)
+	sar.Status.Allowed = c.allowed[""] || c.allowed[ns]
+	return nil
+}
+
+func TestTargetNamespaces(t *testing.T) {
+	cases := map[string]struct {
+		cr   func(*endpointsv1alpha1.Endpoint)
+		old  func(*endpointsv1alpha1.Endpoint)
+		want []string
+	}{
+		"WriteSecretToRef": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) {},
+			want: []string{"spec.forProvider.writeSecretToRef.namespace=krateo-system"},
+		},
+		"VaultOnly": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.WriteSecretToRef = xpv1.SecretReference{}
+				cr.Spec.ForProvider.VaultConfigRef = &xpv1.Reference{Name: "vault"}
+			},
+		},
+		"AdditionalSecretRefs": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.AdditionalSecretRefs = []xpv1.SecretReference{
+					{Name: "dashboard-endpoint", Namespace: "ci"},
+					{Name: "dashboard-token", Namespace: "krateo-system"},
+					{Name: "dashboard-endpoint", Namespace: "team-a"},
+				}
+			},
+			want: []string{
+				"spec.forProvider.writeSecretToRef.namespace=krateo-system",
+				"spec.forProvider.additionalSecretRefs[0].namespace=ci",
+				"spec.forProvider.additionalSecretRefs[2].namespace=team-a",
+			},
+		},
+		"WriteConnectionSecretToRef": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.WriteConnectionSecretToReference = &xpv1.SecretReference{Name: "dashboard-connection", Namespace: "crossplane-system"}
+			},
+			want: []string{
+				"spec.forProvider.writeSecretToRef.namespace=krateo-system",
+				"spec.writeConnectionSecretToRef.namespace=crossplane-system",
+			},
+		},
+		"UnchangedOnUpdate": {
+			cr:  func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.WriteSecretToRef.Name = "dashboard-token" },
+			old: func(cr *endpointsv1alpha1.Endpoint) {},
+		},
+		"ConnectionSecretUnchangedOnUpdate": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.WriteConnectionSecretToReference = &xpv1.SecretReference{Name: "dashboard-token", Namespace: "crossplane-system"}
+			},
+			old: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.WriteConnectionSecretToReference = &xpv1.SecretReference{Name: "dashboard-connection", Namespace: "crossplane-system"}
+			},
+		},
+		"AddedOnUpdate": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.AdditionalSecretRefs = []xpv1.SecretReference{
+					{Name: "dashboard-endpoint", Namespace: "ci"},
+					{Name: "dashboard-endpoint", Namespace: "team-a"},
+				}
+			},
+			old: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.AdditionalSecretRefs = []xpv1.SecretReference{{Name: "dashboard-endpoint", Namespace: "ci"}}
+			},
+			want: []string{"spec.forProvider.additionalSecretRefs[1].namespace=team-a"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := newEndpoint("dashboard")
+			tc.cr(cr)
+
+			var old endpoint
+			if tc.old != nil {
+				o := newEndpoint("dashboard")
+				tc.old(o)
+				old = o
+			}
+
+			var got []string
+			for _, el := range targetNamespaces(field.NewPath("spec", "forProvider"), cr, old) {
+				got = append(got, el.path.String()+"="+el.namespace)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("targetNamespaces(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateAccess(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	restricted := &v1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	restricted.Spec.AllowedNamespaces = []string{"krateo-system", "team-*"}
+
+	cases := map[string]struct {
+		cr           func(*endpointsv1alpha1.Endpoint)
+		old          func(*endpointsv1alpha1.Endpoint)
+		pc           *v1alpha1.ProviderConfig
+		allowed      []string
+		err          error
+		want         []string
+		wantReviewed []string
+		wantErr      error
+	}{
+		"Allowed": {
+			cr:           func(cr *endpointsv1alpha1.Endpoint) {},
+			allowed:      []string{"krateo-system"},
+			wantReviewed: []string{"krateo-system"},
+		},
+		"CannotCreateSecrets": {
+			cr:           func(cr *endpointsv1alpha1.Endpoint) {},
+			want:         []string{"spec.forProvider.writeSecretToRef.namespace"},
+			wantReviewed: []string{"krateo-system"},
+		},
+		"CannotCreateAdditionalSecrets": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.AdditionalSecretRefs = []xpv1.SecretReference{{Name: "dashboard-endpoint", Namespace: "ci"}}
+			},
+			allowed:      []string{"krateo-system"},
+			want:         []string{"spec.forProvider.additionalSecretRefs[0].namespace"},
+			wantReviewed: []string{"krateo-system", "ci"},
+		},
+		"NotAllowedByProviderConfig": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.AdditionalSecretRefs = []xpv1.SecretReference{
+					{Name: "dashboard-endpoint", Namespace: "team-a"},
+					{Name: "dashboard-endpoint", Namespace: "ci"},
+				}
+			},
+			pc:           restricted,
+			allowed:      []string{""},
+			want:         []string{"spec.forProvider.additionalSecretRefs[1].namespace"},
+			wantReviewed: []string{"krateo-system", "team-a"},
+		},
+		"CannotCreateConnectionSecret": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.WriteConnectionSecretToReference = &xpv1.SecretReference{Name: "dashboard-connection", Namespace: "kube-system"}
+			},
+			allowed:      []string{"krateo-system"},
+			want:         []string{"spec.writeConnectionSecretToRef.namespace"},
+			wantReviewed: []string{"krateo-system", "kube-system"},
+		},
+		"ConnectionSecretNotAllowedByProviderConfig": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.WriteConnectionSecretToReference = &xpv1.SecretReference{Name: "dashboard-connection", Namespace: "kube-system"}
+			},
+			pc:           restricted,
+			allowed:      []string{""},
+			want:         []string{"spec.writeConnectionSecretToRef.namespace"},
+			wantReviewed: []string{"krateo-system"},
+		},
+		"UnchangedOnUpdate": {
+			cr:  func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.ExpiresIn = "24h" },
+			old: func(cr *endpointsv1alpha1.Endpoint) {},
+		},
+		"NamespaceSelector": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"krateo.io/tenant": "acme"}}
+			},
+			allowed:      []string{"krateo-system"},
+			want:         []string{"spec.forProvider.namespaceSelector"},
+			wantReviewed: []string{"krateo-system", ""},
+		},
+		"NamespaceSelectorAllNamespaces": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"krateo.io/tenant": "acme"}}
+			},
+			allowed:      []string{""},
+			wantReviewed: []string{"krateo-system", ""},
+		},
+		"ReviewError": {
+			cr:      func(cr *endpointsv1alpha1.Endpoint) {},
+			err:     errBoom,
+			wantErr: errBoom,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := newEndpoint("dashboard")
+			tc.cr(cr)
+
+			var old endpoint
+			if tc.old != nil {
+				o := newEndpoint("dashboard")
+				tc.old(o)
+				old = o
+			}
+
+			kube := &sarClient{Client: newClient(t), allowed: map[string]bool{}, err: tc.err}
+			for _, ns := range tc.allowed {
+				kube.allowed[ns] = true
+			}
+
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: "tenant"},
+			}}
+
+			v := &validator{kube: kube}
+			errs, err := v.validateAccess(context.Background(), req, cr, old, tc.pc)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("validateAccess(...): want error %v, got %v", tc.wantErr, err)
+			}
+			if diff := cmp.Diff(tc.want, fields(errs)); diff != "" {
+				t.Errorf("validateAccess(...): -want fields, +got fields:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantReviewed, kube.reviewed); diff != "" {
+				t.Errorf("validateAccess(...): -want reviewed namespaces, +got reviewed namespaces:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients/accounts"
+)
+
+const (
+	// ValidatePath is the path the Endpoint validating webhook is served at.
+	ValidatePath = "/validate-argocd-krateo-io-v1alpha1-endpoint"
+	// ValidateNamespacedPath is the path the NamespacedEndpoint validating
+	// webhook is served at.
+	ValidateNamespacedPath = "/validate-argocd-krateo-io-v1alpha1-namespacedendpoint"
+
+	// maxAccountLength is the longest ArgoCD account name accepted.
+	maxAccountLength = 63
+)
+
+// accountRegexp matches the ArgoCD local account names: they are used as
+// keys of the argocd-cm ConfigMap (accounts.<name>) and as JWT subjects.
+var accountRegexp = regexp.MustCompile(`^[a-z0-9]([-_.a-z0-9]*[a-z0-9])?$`)
+
+// +kubebuilder:webhook:path=/validate-argocd-krateo-io-v1alpha1-endpoint,mutating=false,failurePolicy=fail,sideEffects=None,groups=argocd.krateo.io,resources=endpoints,verbs=create;update,versions=v1alpha1,name=endpoints.argocd.krateo.io,admissionReviewVersions=v1
+
+// +kubebuilder:webhook:path=/validate-argocd-krateo-io-v1alpha1-namespacedendpoint,mutating=false,failurePolicy=fail,sideEffects=None,groups=argocd.krateo.io,resources=namespacedendpoints,verbs=create;update,versions=v1alpha1,name=namespacedendpoints.argocd.krateo.io,admissionReviewVersions=v1
+
+// Setup registers the Endpoint and NamespacedEndpoint validating webhooks.
+func Setup(mgr ctrl.Manager, log logging.Logger) error {
+	mgr.GetWebhookServer().Register(ValidatePath, &webhook.Admission{
+		Handler: &validator{
+			kube:   mgr.GetClient(),
+			log:    log.WithValues("webhook", ValidatePath),
+			newObj: func() endpoint { return &endpointsv1alpha1.Endpoint{} },
+		},
+	})
+	mgr.GetWebhookServer().Register(ValidateNamespacedPath, &webhook.Admission{
+		Handler: &validator{
+			kube:   mgr.GetClient(),
+			log:    log.WithValues("webhook", ValidateNamespacedPath),
+			newObj: func() endpoint { return &endpointsv1alpha1.NamespacedEndpoint{} },
+		},
+	})
+	return nil
+}
+
+// endpoint is the resource validated by this package: either an Endpoint
+// or a NamespacedEndpoint.
+type endpoint interface {
+	resource.Managed
+	endpointsv1alpha1.TokenEndpoint
+}
+
+type validator struct {
+	kube    client.Client
+	log     logging.Logger
+	decoder *admission.Decoder
+	newObj  func() endpoint
+}
+
+// InjectDecoder injects the admission decoder.
+func (v *validator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+func (v *validator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	cr := v.newObj()
+	if err := v.decoder.Decode(req, cr); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// never block the removal of the finalizers of a deleted Endpoint
+	if cr.GetDeletionTimestamp() != nil {
+		return admission.Allowed("")
+	}
+
+	errs := validateSpec(cr)
+
+	var old endpoint
+	if req.Operation == admissionv1.Update {
+		old = v.newObj()
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		errs = append(errs, validateUpdate(cr, old)...)
+	}
+
+	if len(errs) == 0 {
+		dup, err := v.validateUniqueSecrets(ctx, cr)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		errs = append(errs, dup...)
+	}
+
+	if len(errs) == 0 {
+		pc, pcb, err := v.providerConfig(ctx, cr)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+
+		denied, err := v.validateAccess(ctx, req, cr, old, pc)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		errs = append(errs, denied...)
+
+		errs = append(errs, validatePolicy(cr, old, pc, pcb)...)
+	}
+
+	if len(errs) > 0 {
+		v.log.Debug("Rejected endpoint", "name", cr.GetName(), "namespace", cr.GetNamespace(), "reason", errs.ToAggregate().Error())
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+
+	return admission.Allowed("")
+}
+
+// validateSpec checks the fields of the supplied Endpoint.
+func validateSpec(cr endpoint) field.ErrorList {
+	spec := cr.GetParameters()
+	path := field.NewPath("spec", "forProvider")
+
+	errs := field.ErrorList{}
+
+	switch {
+	case len(spec.Account) == 0:
+		errs = append(errs, field.Required(path.Child("account"), "the ArgoCD account name is required"))
+	case len(spec.Account) > maxAccountLength:
+		errs = append(errs, field.TooLong(path.Child("account"), spec.Account, maxAccountLength))
+	case !accountRegexp.MatchString(spec.Account):
+		errs = append(errs, field.Invalid(path.Child("account"), spec.Account,
+			"must consist of lower case alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character"))
+	}
+
+	if len(spec.WriteSecretToRef.Name) == 0 && spec.VaultConfigRef == nil {
+		errs = append(errs, field.Required(path.Child("writeSecretToRef"), "either writeSecretToRef or vaultConfigRef must be specified"))
+	}
+
+	ref := spec.WriteSecretToRef
+	if len(ref.Name) > 0 || len(ref.Namespace) > 0 {
+		errs = append(errs, validateSecretRef(path.Child("writeSecretToRef"), ref)...)
+	}
+
+	if len(spec.AdditionalSecretRefs) > 0 && len(spec.WriteSecretToRef.Name) == 0 {
+		errs = append(errs, field.Required(path.Child("writeSecretToRef"), "additionalSecretRefs require writeSecretToRef"))
+	}
+	for i, ref := range spec.AdditionalSecretRefs {
+		errs = append(errs, validateSecretRef(path.Child("additionalSecretRefs").Index(i), ref)...)
+	}
+
+	if err := clients.ValidateSecretTemplate(spec.SecretTemplate); err != nil {
+		errs = append(errs, field.Invalid(path.Child("secretTemplate"), "", err.Error()))
+	}
+	if spec.Encryption != nil {
+		if err := clients.ValidateEncryptedSecretTemplate(spec.SecretTemplate); err != nil {
+			errs = append(errs, field.Invalid(path.Child("secretTemplate"), "", err.Error()))
+		}
+	}
+
+	if sel := cr.GetProviderConfigSelector(); sel != nil {
+		errs = append(errs, metav1validation.ValidateLabelSelector(sel, field.NewPath("spec", "providerConfigSelector"))...)
+	}
+
+	// a NamespacedEndpoint never writes outside of its namespace
+	if ref := cr.GetWriteConnectionSecretToReference(); ref != nil && len(cr.GetNamespace()) > 0 && ref.Namespace != cr.GetNamespace() {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "writeConnectionSecretToRef", "namespace"), ref.Namespace,
+			"must be the namespace of the NamespacedEndpoint"))
+	}
+	if ne, ok := cr.(*endpointsv1alpha1.NamespacedEndpoint); ok {
+		if enc := ne.Spec.ForProvider.Encryption; enc != nil && enc.PublicKeyRef.Namespace != ne.GetNamespace() {
+			errs = append(errs, field.Invalid(path.Child("encryption", "publicKeyRef", "namespace"), enc.PublicKeyRef.Namespace,
+				"must be the namespace of the NamespacedEndpoint"))
+		}
+	}
+
+	return errs
+}
+
+// validateSecretRef checks that the supplied secret reference has a valid
+// name and namespace.
+func validateSecretRef(path *field.Path, ref xpv1.SecretReference) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if len(ref.Name) == 0 {
+		errs = append(errs, field.Required(path.Child("name"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+			errs = append(errs, field.Invalid(path.Child("name"), ref.Name, msg))
+		}
+	}
+
+	if len(ref.Namespace) == 0 {
+		errs = append(errs, field.Required(path.Child("namespace"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Label(ref.Namespace) {
+			errs = append(errs, field.Invalid(path.Child("namespace"), ref.Namespace, msg))
+		}
+	}
+
+	return errs
+}
+
+// validateUpdate checks that the immutable fields have not been changed:
+// a new account or token id would leave the old token behind.
+func validateUpdate(cr, old endpoint) field.ErrorList {
+	path := field.NewPath("spec", "forProvider")
+
+	errs := field.ErrorList{}
+
+	if cr.GetParameters().Account != old.GetParameters().Account {
+		errs = append(errs, field.Forbidden(path.Child("account"), "field is immutable"))
+	}
+
+	if cr.GetParameters().ID != old.GetParameters().ID {
+		errs = append(errs, field.Forbidden(path.Child("id"), "field is immutable"))
+	}
+
+	return errs
+}
+
+// validatePolicy checks that the account policies of the ProviderConfig,
+// and of the ProviderConfigBinding if any, allow the token requested by the Endpoint. On update the policy is only
+// checked if the requested token changed, so that the provider can still
+// update Endpoints created before the policy.
+func validatePolicy(cr, old endpoint, pc *v1alpha1.ProviderConfig, pcb *v1alpha1.ProviderConfigBinding) field.ErrorList {
+	if pc == nil {
+		return nil
+	}
+
+	policies := []*v1alpha1.AccountPolicy{pc.Spec.AccountPolicy}
+	if pcb != nil {
+		policies = append(policies, pcb.Spec.AccountPolicy)
+	}
+
+	spec := cr.GetParameters()
+	if old != nil && old.GetParameters().Account == spec.Account && old.GetParameters().ExpiresIn == spec.ExpiresIn {
+		return nil
+	}
+
+	path := field.NewPath("spec", "forProvider")
+
+	var expiresIn time.Duration
+	if len(spec.ExpiresIn) > 0 {
+		d, err := time.ParseDuration(spec.ExpiresIn)
+		if err != nil {
+			return field.ErrorList{field.Invalid(path.Child("expiresIn"), spec.ExpiresIn, err.Error())}
+		}
+		expiresIn = d
+	}
+
+	// a token provider without expiration support issues non-expiring tokens
+	if caps, err := accounts.CapabilitiesOf(accounts.Backend(pc.Spec.TokenProvider)); err == nil && !caps.Expiry {
+		expiresIn = 0
+	}
+
+	for _, p := range policies {
+		if err := clients.CheckAccountPolicy(p, spec.Account, expiresIn); err != nil {
+			return field.ErrorList{field.Forbidden(path, err.Error())}
+		}
+	}
+
+	return nil
+}
+
+// validateUniqueSecrets checks that no other Endpoint, or
+// NamespacedEndpoint, writes to the secrets explicitly referenced by the
+// supplied one.
+func (v *validator) validateUniqueSecrets(ctx context.Context, cr endpoint) (field.ErrorList, error) {
+	spec := cr.GetParameters()
+	fp := field.NewPath("spec", "forProvider")
+
+	paths := map[xpv1.SecretReference]*field.Path{}
+	if len(spec.WriteSecretToRef.Name) > 0 {
+		paths[spec.WriteSecretToRef] = fp.Child("writeSecretToRef")
+	}
+	for i, ref := range spec.AdditionalSecretRefs {
+		if _, ok := paths[ref]; !ok {
+			paths[ref] = fp.Child("additionalSecretRefs").Index(i)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	others, err := v.listOthers(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := field.ErrorList{}
+	for _, el := range others {
+		p := el.GetParameters()
+		for _, ref := range append([]xpv1.SecretReference{p.WriteSecretToRef}, p.AdditionalSecretRefs...) {
+			if path, ok := paths[ref]; ok {
+				errs = append(errs, field.Duplicate(path, fmt.Sprintf("%s/%s (written by %s %s)", ref.Namespace, ref.Name, kindOf(el), name(el))))
+				delete(paths, ref)
+			}
+		}
+	}
+
+	return errs, nil
+}
+
+// listOthers returns all the Endpoints and NamespacedEndpoints except the
+// supplied one.
+func (v *validator) listOthers(ctx context.Context, cr endpoint) ([]endpointsv1alpha1.TokenEndpoint, error) {
+	eps := &endpointsv1alpha1.EndpointList{}
+	if err := v.kube.List(ctx, eps); err != nil {
+		return nil, err
+	}
+
+	neps := &endpointsv1alpha1.NamespacedEndpointList{}
+	if err := v.kube.List(ctx, neps); err != nil {
+		return nil, err
+	}
+
+	res := make([]endpointsv1alpha1.TokenEndpoint, 0, len(eps.Items)+len(neps.Items))
+	for i := range eps.Items {
+		res = append(res, &eps.Items[i])
+	}
+	for i := range neps.Items {
+		res = append(res, &neps.Items[i])
+	}
+
+	self := kindOf(cr) + "/" + name(cr)
+	for i := 0; i < len(res); i++ {
+		if kindOf(res[i])+"/"+name(res[i]) == self {
+			res = append(res[:i], res[i+1:]...)
+			break
+		}
+	}
+
+	return res, nil
+}
+
+// kindOf returns the kind of the supplied endpoint.
+func kindOf(o endpointsv1alpha1.TokenEndpoint) string {
+	if _, ok := o.(*endpointsv1alpha1.NamespacedEndpoint); ok {
+		return endpointsv1alpha1.NamespacedEndpointKind
+	}
+	return endpointsv1alpha1.EndpointKind
+}
+
+// name returns the, namespace qualified if namespaced, name of the
+// supplied endpoint.
+func name(o endpointsv1alpha1.TokenEndpoint) string {
+	if ns := o.GetNamespace(); len(ns) > 0 {
+		return ns + "/" + o.GetName()
+	}
+	return o.GetName()
+}
@@ -0,0 +1,393 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis"
+	endpointsv1alpha1 "github.com/krateoplatformops/provider-argocd-endpoint/apis/endpoints/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+)
+
+func newEndpoint(name string) *endpointsv1alpha1.Endpoint {
+	cr := &endpointsv1alpha1.Endpoint{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	cr.Spec.ForProvider.Account = "krateo-dashboard"
+	cr.Spec.ForProvider.WriteSecretToRef = xpv1.SecretReference{Name: name + "-endpoint", Namespace: "krateo-system"}
+	return cr
+}
+
+func newNamespacedEndpoint(name, ns string) *endpointsv1alpha1.NamespacedEndpoint {
+	cr := &endpointsv1alpha1.NamespacedEndpoint{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}}
+	cr.Spec.ForProvider.Account = "krateo-dashboard"
+	cr.Spec.ForProvider.WriteSecretToRef = xpv1.LocalSecretReference{Name: name + "-endpoint"}
+	return cr
+}
+
+func newClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+
+	s := runtime.NewScheme()
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build()
+}
+
+// fields returns the paths of the fields with errors.
+func fields(errs field.ErrorList) []string {
+	var res []string
+	for _, err := range errs {
+		res = append(res, err.Field)
+	}
+	return res
+}
+
+func TestValidateSpec(t *testing.T) {
+	cases := map[string]struct {
+		cr   func(*endpointsv1alpha1.Endpoint)
+		want []string
+	}{
+		"Valid": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {},
+		},
+		"NoAccount": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.Account = "" },
+			want: []string{"spec.forProvider.account"},
+		},
+		"AccountTooLong": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.Account = "a123456789012345678901234567890123456789012345678901234567890123"
+			},
+			want: []string{"spec.forProvider.account"},
+		},
+		"InvalidAccount": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.Account = "Dashboard" },
+			want: []string{"spec.forProvider.account"},
+		},
+		"NoSink": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.WriteSecretToRef = xpv1.SecretReference{} },
+			want: []string{"spec.forProvider.writeSecretToRef"},
+		},
+		"VaultOnly": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.WriteSecretToRef = xpv1.SecretReference{}
+				cr.Spec.ForProvider.VaultConfigRef = &xpv1.Reference{Name: "vault"}
+			},
+		},
+		"NoSecretNamespace": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.WriteSecretToRef.Namespace = "" },
+			want: []string{"spec.forProvider.writeSecretToRef.namespace"},
+		},
+		"InvalidSecretName": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.WriteSecretToRef.Name = "Dashboard_Endpoint" },
+			want: []string{"spec.forProvider.writeSecretToRef.name"},
+		},
+		"AdditionalSecretRefs": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.AdditionalSecretRefs = []xpv1.SecretReference{
+					{Name: "dashboard-endpoint", Namespace: "ci"},
+					{Name: "dashboard-endpoint"},
+				}
+			},
+			want: []string{"spec.forProvider.additionalSecretRefs[1].namespace"},
+		},
+		"AdditionalSecretRefsWithoutWriteSecretToRef": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.WriteSecretToRef = xpv1.SecretReference{}
+				cr.Spec.ForProvider.VaultConfigRef = &xpv1.Reference{Name: "vault"}
+				cr.Spec.ForProvider.AdditionalSecretRefs = []xpv1.SecretReference{{Name: "dashboard-endpoint", Namespace: "ci"}}
+			},
+			want: []string{"spec.forProvider.writeSecretToRef"},
+		},
+		"InvalidSecretTemplate": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.SecretTemplate = &endpointsv1alpha1.SecretTemplate{TokenKey: "target"}
+			},
+			want: []string{"spec.forProvider.secretTemplate"},
+		},
+		"InvalidSecretTemplateKey": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.SecretTemplate = &endpointsv1alpha1.SecretTemplate{Data: map[string]string{"a/b": "{{ .Account }}"}}
+			},
+			want: []string{"spec.forProvider.secretTemplate"},
+		},
+		"InvalidProviderConfigSelector": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ProviderConfigSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"-": "argocd"}}
+			},
+			want: []string{"spec.providerConfigSelector.matchLabels"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := newEndpoint("dashboard")
+			tc.cr(cr)
+
+			if diff := cmp.Diff(tc.want, fields(validateSpec(cr))); diff != "" {
+				t.Errorf("validateSpec(...): -want fields, +got fields:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateNamespacedSpec(t *testing.T) {
+	cases := map[string]struct {
+		cr   func(*endpointsv1alpha1.NamespacedEndpoint)
+		want []string
+	}{
+		"Valid": {
+			cr: func(cr *endpointsv1alpha1.NamespacedEndpoint) {},
+		},
+		"ConnectionSecretInOwnNamespace": {
+			cr: func(cr *endpointsv1alpha1.NamespacedEndpoint) {
+				cr.Spec.WriteConnectionSecretToReference = &xpv1.SecretReference{Name: "dashboard-connection", Namespace: "team-a"}
+			},
+		},
+		"ConnectionSecretInOtherNamespace": {
+			cr: func(cr *endpointsv1alpha1.NamespacedEndpoint) {
+				cr.Spec.WriteConnectionSecretToReference = &xpv1.SecretReference{Name: "dashboard-connection", Namespace: "kube-system"}
+			},
+			want: []string{"spec.writeConnectionSecretToRef.namespace"},
+		},
+		"ConnectionSecretWithoutNamespace": {
+			cr: func(cr *endpointsv1alpha1.NamespacedEndpoint) {
+				cr.Spec.WriteConnectionSecretToReference = &xpv1.SecretReference{Name: "dashboard-connection"}
+			},
+			want: []string{"spec.writeConnectionSecretToRef.namespace"},
+		},
+		"PublicKeyInOwnNamespace": {
+			cr: func(cr *endpointsv1alpha1.NamespacedEndpoint) {
+				cr.Spec.ForProvider.Encryption = &endpointsv1alpha1.TokenEncryption{
+					PublicKeyRef: endpointsv1alpha1.PublicKeyReference{Name: "dashboard-key", Namespace: "team-a", Key: "key.pem"},
+				}
+			},
+		},
+		"PublicKeyInOtherNamespace": {
+			cr: func(cr *endpointsv1alpha1.NamespacedEndpoint) {
+				cr.Spec.ForProvider.Encryption = &endpointsv1alpha1.TokenEncryption{
+					PublicKeyRef: endpointsv1alpha1.PublicKeyReference{Name: "argocd-secret", Namespace: "argocd", Key: "server.secretkey"},
+				}
+			},
+			want: []string{"spec.forProvider.encryption.publicKeyRef.namespace"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := newNamespacedEndpoint("dashboard", "team-a")
+			tc.cr(cr)
+
+			if diff := cmp.Diff(tc.want, fields(validateSpec(cr))); diff != "" {
+				t.Errorf("validateSpec(...): -want fields, +got fields:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	cases := map[string]struct {
+		cr   func(*endpointsv1alpha1.Endpoint)
+		want []string
+	}{
+		"Unchanged": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {},
+		},
+		"MutableFields": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.ExpiresIn = "24h"
+				cr.Spec.ForProvider.WriteSecretToRef.Name = "dashboard-token"
+			},
+		},
+		"Account": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.Account = "ci" },
+			want: []string{"spec.forProvider.account"},
+		},
+		"ID": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.ID = "ci" },
+			want: []string{"spec.forProvider.id"},
+		},
+		"AccountAndID": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.Account = "ci"
+				cr.Spec.ForProvider.ID = "ci"
+			},
+			want: []string{"spec.forProvider.account", "spec.forProvider.id"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			old := newEndpoint("dashboard")
+			cr := old.DeepCopy()
+			tc.cr(cr)
+
+			if diff := cmp.Diff(tc.want, fields(validateUpdate(cr, old))); diff != "" {
+				t.Errorf("validateUpdate(...): -want fields, +got fields:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateUniqueSecrets(t *testing.T) {
+	other := newEndpoint("other")
+	other.Spec.ForProvider.WriteSecretToRef = xpv1.SecretReference{Name: "dashboard-endpoint", Namespace: "krateo-system"}
+
+	tenant := newNamespacedEndpoint("dashboard", "team-a")
+
+	cases := map[string]struct {
+		cr   func(*endpointsv1alpha1.Endpoint)
+		objs []client.Object
+		want []string
+	}{
+		"NoOtherEndpoints": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {},
+		},
+		"Itself": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) {},
+			objs: []client.Object{newEndpoint("dashboard")},
+		},
+		"OtherSecrets": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) {},
+			objs: []client.Object{newEndpoint("ci"), newNamespacedEndpoint("ci", "team-a")},
+		},
+		"WrittenByEndpoint": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) {},
+			objs: []client.Object{other},
+			want: []string{"spec.forProvider.writeSecretToRef"},
+		},
+		"WrittenByNamespacedEndpoint": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.AdditionalSecretRefs = []xpv1.SecretReference{
+					{Name: "ci-endpoint", Namespace: "team-a"},
+					{Name: "dashboard-endpoint", Namespace: "team-a"},
+				}
+			},
+			objs: []client.Object{tenant},
+			want: []string{"spec.forProvider.additionalSecretRefs[1]"},
+		},
+		"VaultOnly": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.WriteSecretToRef = xpv1.SecretReference{}
+				cr.Spec.ForProvider.VaultConfigRef = &xpv1.Reference{Name: "vault"}
+			},
+			objs: []client.Object{other},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := newEndpoint("dashboard")
+			tc.cr(cr)
+
+			v := &validator{kube: newClient(t, tc.objs...)}
+			errs, err := v.validateUniqueSecrets(context.Background(), cr)
+			if err != nil {
+				t.Fatalf("validateUniqueSecrets(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, fields(errs)); diff != "" {
+				t.Errorf("validateUniqueSecrets(...): -want fields, +got fields:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	no := false
+
+	pc := &v1alpha1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	pc.Spec.AccountPolicy = &v1alpha1.AccountPolicy{DeniedAccounts: []string{"admin"}, MaxTokenLifetime: "720h"}
+
+	pcb := &v1alpha1.ProviderConfigBinding{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "team-a"}}
+	pcb.Spec.AccountPolicy = &v1alpha1.AccountPolicy{AllowedAccounts: []string{"team-a-*"}, AllowNonExpiringTokens: &no}
+
+	cases := map[string]struct {
+		cr   func(*endpointsv1alpha1.Endpoint)
+		old  func(*endpointsv1alpha1.Endpoint)
+		pc   *v1alpha1.ProviderConfig
+		pcb  *v1alpha1.ProviderConfigBinding
+		want []string
+	}{
+		"NoProviderConfig": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.Account = "admin" },
+		},
+		"NoPolicy": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {},
+			pc: &v1alpha1.ProviderConfig{},
+		},
+		"Allowed": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.ExpiresIn = "24h" },
+			pc: pc,
+		},
+		"Denied": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.Account = "admin"
+				cr.Spec.ForProvider.ExpiresIn = "24h"
+			},
+			pc:   pc,
+			want: []string{"spec.forProvider"},
+		},
+		"NonExpiring": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) {},
+			pc:   pc,
+			want: []string{"spec.forProvider"},
+		},
+		"InvalidExpiresIn": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.ExpiresIn = "a day" },
+			pc:   pc,
+			want: []string{"spec.forProvider.expiresIn"},
+		},
+		"AllowedByBinding": {
+			cr: func(cr *endpointsv1alpha1.Endpoint) {
+				cr.Spec.ForProvider.Account = "team-a-ci"
+				cr.Spec.ForProvider.ExpiresIn = "24h"
+			},
+			pc:  pc,
+			pcb: pcb,
+		},
+		"NotAllowedByBinding": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.ExpiresIn = "24h" },
+			pc:   pc,
+			pcb:  pcb,
+			want: []string{"spec.forProvider"},
+		},
+		"UnchangedOnUpdate": {
+			cr:  func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.WriteSecretToRef.Name = "dashboard-token" },
+			old: func(cr *endpointsv1alpha1.Endpoint) {},
+			pc:  pc,
+		},
+		"ExpiresInChangedOnUpdate": {
+			cr:   func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.ExpiresIn = "1000h" },
+			old:  func(cr *endpointsv1alpha1.Endpoint) { cr.Spec.ForProvider.ExpiresIn = "24h" },
+			pc:   pc,
+			want: []string{"spec.forProvider"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := newEndpoint("dashboard")
+			tc.cr(cr)
+
+			var old endpoint
+			if tc.old != nil {
+				o := newEndpoint("dashboard")
+				tc.old(o)
+				old = o
+			}
+
+			if diff := cmp.Diff(tc.want, fields(validatePolicy(cr, old, tc.pc, tc.pcb))); diff != "" {
+				t.Errorf("validatePolicy(...): -want fields, +got fields:\n%s", diff)
+			}
+		})
+	}
+}
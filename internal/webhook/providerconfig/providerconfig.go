@@ -0,0 +1,142 @@
+package providerconfig
+
+import (
+	"context"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/clients"
+)
+
+const (
+	// ValidatePath is the path the ProviderConfig validating webhook is
+	// served at.
+	ValidatePath = "/validate-argocd-krateo-io-v1alpha1-providerconfig"
+	// ValidateBindingPath is the path the ProviderConfigBinding validating
+	// webhook is served at.
+	ValidateBindingPath = "/validate-argocd-krateo-io-v1alpha1-providerconfigbinding"
+)
+
+// +kubebuilder:webhook:path=/validate-argocd-krateo-io-v1alpha1-providerconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=argocd.krateo.io,resources=providerconfigs,verbs=create;update,versions=v1alpha1,name=providerconfigs.argocd.krateo.io,admissionReviewVersions=v1
+
+// +kubebuilder:webhook:path=/validate-argocd-krateo-io-v1alpha1-providerconfigbinding,mutating=false,failurePolicy=fail,sideEffects=None,groups=argocd.krateo.io,resources=providerconfigbindings,verbs=create;update,versions=v1alpha1,name=providerconfigbindings.argocd.krateo.io,admissionReviewVersions=v1
+
+// Setup registers the ProviderConfig and ProviderConfigBinding validating
+// webhooks.
+func Setup(mgr ctrl.Manager, log logging.Logger) error {
+	mgr.GetWebhookServer().Register(ValidatePath, &webhook.Admission{
+		Handler: &validator{log: log.WithValues("webhook", ValidatePath)},
+	})
+	mgr.GetWebhookServer().Register(ValidateBindingPath, &webhook.Admission{
+		Handler: &bindingValidator{log: log.WithValues("webhook", ValidateBindingPath)},
+	})
+	return nil
+}
+
+type validator struct {
+	log     logging.Logger
+	decoder *admission.Decoder
+}
+
+// InjectDecoder injects the admission decoder.
+func (v *validator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+func (v *validator) Handle(_ context.Context, req admission.Request) admission.Response {
+	pc := &v1alpha1.ProviderConfig{}
+	if err := v.decoder.Decode(req, pc); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// never block the removal of the finalizers of a deleted ProviderConfig
+	if pc.GetDeletionTimestamp() != nil {
+		return admission.Allowed("")
+	}
+
+	if errs := validateSpec(pc); len(errs) > 0 {
+		v.log.Debug("Rejected ProviderConfig", "name", pc.GetName(), "reason", errs.ToAggregate().Error())
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+
+	return admission.Allowed("")
+}
+
+// validateSpec checks the fields of the supplied ProviderConfig.
+func validateSpec(pc *v1alpha1.ProviderConfig) field.ErrorList {
+	path := field.NewPath("spec")
+
+	errs := field.ErrorList{}
+
+	switch {
+	case len(pc.Spec.ServerUrl) == 0 && pc.Spec.ServerRef == nil:
+		errs = append(errs, field.Required(path.Child("serverUrl"), "either serverUrl or serverRef must be specified"))
+	case len(pc.Spec.ServerUrl) > 0 && pc.Spec.ServerRef != nil:
+		errs = append(errs, field.Forbidden(path.Child("serverRef"), "serverUrl and serverRef are mutually exclusive"))
+	}
+
+	errs = append(errs, validatePatterns(path.Child("allowedNamespaces"), pc.Spec.AllowedNamespaces)...)
+	errs = append(errs, validateAccountPolicy(path.Child("accountPolicy"), pc.Spec.AccountPolicy)...)
+
+	return errs
+}
+
+type bindingValidator struct {
+	log     logging.Logger
+	decoder *admission.Decoder
+}
+
+// InjectDecoder injects the admission decoder.
+func (v *bindingValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+func (v *bindingValidator) Handle(_ context.Context, req admission.Request) admission.Response {
+	pcb := &v1alpha1.ProviderConfigBinding{}
+	if err := v.decoder.Decode(req, pcb); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if pcb.GetDeletionTimestamp() != nil {
+		return admission.Allowed("")
+	}
+
+	if errs := validateAccountPolicy(field.NewPath("spec", "accountPolicy"), pcb.Spec.AccountPolicy); len(errs) > 0 {
+		v.log.Debug("Rejected ProviderConfigBinding", "name", pcb.GetName(), "namespace", pcb.GetNamespace(), "reason", errs.ToAggregate().Error())
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+
+	return admission.Allowed("")
+}
+
+// validateAccountPolicy checks the account patterns of the supplied policy:
+// the controller refuses all the tokens of a policy with a malformed one.
+func validateAccountPolicy(path *field.Path, p *v1alpha1.AccountPolicy) field.ErrorList {
+	if p == nil {
+		return nil
+	}
+
+	errs := validatePatterns(path.Child("allowedAccounts"), p.AllowedAccounts)
+	return append(errs, validatePatterns(path.Child("deniedAccounts"), p.DeniedAccounts)...)
+}
+
+// validatePatterns checks that the supplied names or shell patterns are
+// well formed.
+func validatePatterns(path *field.Path, patterns []string) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, el := range patterns {
+		if !clients.IsValidPattern(el) {
+			errs = append(errs, field.Invalid(path.Index(i), el, "must be a name or a valid shell pattern"))
+		}
+	}
+	return errs
+}
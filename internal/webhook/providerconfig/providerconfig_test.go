@@ -0,0 +1,74 @@
+package providerconfig
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/apis/v1alpha1"
+)
+
+func TestValidateSpec(t *testing.T) {
+	cases := map[string]struct {
+		spec func(*v1alpha1.ProviderConfigSpec)
+		want []string
+	}{
+		"ServerURL": {
+			spec: func(s *v1alpha1.ProviderConfigSpec) { s.ServerUrl = "https://argocd.example.com" },
+		},
+		"ServerRef": {
+			spec: func(s *v1alpha1.ProviderConfigSpec) { s.ServerRef = &v1alpha1.ServiceReference{Namespace: "argocd"} },
+		},
+		"NoServer": {
+			spec: func(s *v1alpha1.ProviderConfigSpec) {},
+			want: []string{"spec.serverUrl"},
+		},
+		"ServerURLAndServerRef": {
+			spec: func(s *v1alpha1.ProviderConfigSpec) {
+				s.ServerUrl = "https://argocd.example.com"
+				s.ServerRef = &v1alpha1.ServiceReference{Namespace: "argocd"}
+			},
+			want: []string{"spec.serverRef"},
+		},
+		"AccountPolicy": {
+			spec: func(s *v1alpha1.ProviderConfigSpec) {
+				s.ServerUrl = "https://argocd.example.com"
+				s.AllowedNamespaces = []string{"argocd", "team-*"}
+				s.AccountPolicy = &v1alpha1.AccountPolicy{AllowedAccounts: []string{"ci-*"}, DeniedAccounts: []string{"admin"}}
+			},
+		},
+		"InvalidPatterns": {
+			spec: func(s *v1alpha1.ProviderConfigSpec) {
+				s.ServerUrl = "https://argocd.example.com"
+				s.AllowedNamespaces = []string{"argocd", "team-["}
+				s.AccountPolicy = &v1alpha1.AccountPolicy{AllowedAccounts: []string{"ci-["}, DeniedAccounts: []string{"admin", "adm["}}
+			},
+			want: []string{
+				"spec.allowedNamespaces[1]",
+				"spec.accountPolicy.allowedAccounts[0]",
+				"spec.accountPolicy.deniedAccounts[1]",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			pc := &v1alpha1.ProviderConfig{}
+			tc.spec(&pc.Spec)
+
+			if diff := cmp.Diff(tc.want, fields(validateSpec(pc))); diff != "" {
+				t.Errorf("validateSpec(...): -want fields, +got fields:\n%s", diff)
+			}
+		})
+	}
+}
+
+// fields returns the paths of the fields with errors.
+func fields(errs field.ErrorList) []string {
+	var res []string
+	for _, err := range errs {
+		res = append(res, err.Field)
+	}
+	return res
+}
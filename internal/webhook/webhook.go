@@ -0,0 +1,23 @@
+package webhook
+
+import (
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/webhook/endpoint"
+	"github.com/krateoplatformops/provider-argocd-endpoint/internal/webhook/providerconfig"
+)
+
+// Setup registers all the admission webhooks with the supplied manager.
+func Setup(mgr ctrl.Manager, log logging.Logger) error {
+	for _, setup := range []func(ctrl.Manager, logging.Logger) error{
+		endpoint.Setup,
+		providerconfig.Setup,
+	} {
+		if err := setup(mgr, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}